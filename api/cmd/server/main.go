@@ -6,7 +6,6 @@ import (
 	"crypto/rand"
 	"embed"
 	"encoding/hex"
-	"fmt"
 	"io/fs"
 	"log"
 	"net/http"
@@ -16,9 +15,8 @@ import (
 	"syscall"
 	"time"
 
-	"golang.org/x/crypto/bcrypt"
-
 	"bot-manager/internal/api"
+	"bot-manager/internal/botrunner"
 	"bot-manager/internal/config"
 	"bot-manager/internal/db"
 	"bot-manager/internal/manager"
@@ -78,9 +76,21 @@ func main() {
 	}
 
 	migrSub, _ := fs.Sub(migrationsFS, "migrations")
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(ctx, database, migrSub, os.Args[2:]); err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+		return
+	}
 	if err := database.RunMigrations(ctx, migrSub); err != nil {
 		log.Fatalf("migrations: %v", err)
 	}
+	if len(os.Args) > 1 && os.Args[1] == "reset-password" {
+		if err := runResetPassword(ctx, database, cfg.AdminUsername, os.Args[2:]); err != nil {
+			log.Fatalf("reset-password: %v", err)
+		}
+		return
+	}
 
 	// Session secret
 	sessionSecret := cfg.SessionSecret
@@ -95,18 +105,9 @@ func main() {
 		sessionSecret, _ = hex.DecodeString(stored)
 	}
 
-	// Admin password hash (bcrypt), stored in DB
-	passwordHash, err := database.GetSetting(ctx, "admin_password_hash")
-	if err != nil || passwordHash == "" {
-		plain := cfg.AdminPassword
-		hash, err := bcrypt.GenerateFromPassword([]byte(plain), bcrypt.DefaultCost)
-		if err != nil {
-			log.Fatalf("bcrypt: %v", err)
-		}
-		passwordHash = string(hash)
-		database.SetSetting(ctx, "admin_password_hash", passwordHash)
-		fmt.Printf("\n=== WebUI Credentials ===\nUsername: %s\nPassword: %s\n=========================\n\n",
-			cfg.AdminUsername, plain)
+	// Accounts: the first run creates the owner
+	if err := ensureOwner(ctx, database, cfg.AdminUsername, cfg.AdminPassword); err != nil {
+		log.Fatalf("users: %v", err)
 	}
 
 	// MinIO
@@ -119,18 +120,41 @@ func main() {
 	}
 
 	// Bot manager
-	mgr := manager.New(database, minio)
+	mgr := manager.New(database, minio, manager.Config{
+		InstanceID:  cfg.InstanceID,
+		InstanceURL: cfg.InstanceURL,
+		LeaseTTL:    cfg.LeaseTTL,
+		Watchdog: manager.WatchdogConfig{
+			Interval:     cfg.WatchdogInterval,
+			PollStall:    cfg.WatchdogPollStall,
+			HandlerStall: cfg.WatchdogHandlerStall,
+			AutoRestart:  cfg.WatchdogAutoRestart,
+		},
+		Tokens: cfg.TokenKeys,
+		Telegram: botrunner.Connection{
+			Endpoint: cfg.TelegramAPIEndpoint,
+			Proxy:    cfg.TelegramProxy,
+		},
+	})
+	if cfg.TokenKeys == nil {
+		log.Println("WARNING: TOKEN_ENCRYPTION_KEYS is not set, bot tokens are stored in plaintext")
+	} else if n, err := mgr.EncryptTokens(ctx); err != nil {
+		log.Fatalf("encrypt tokens: %v", err)
+	} else if n > 0 {
+		log.Printf("Encrypted %d bot token(s) with the current key", n)
+	}
 	mgr.StartAll(ctx)
 
 	// Frontend FS (nil-safe: server works without frontend in dev mode)
 	distSub, _ := fs.Sub(frontendFS, "frontend_dist")
 
 	// HTTP server
-	srv := api.NewServer(database, minio, mgr, cfg.AdminUsername, passwordHash, sessionSecret, distSub)
+	srv := api.NewServer(database, minio, mgr, sessionSecret, distSub)
 	httpServer := &http.Server{
 		Addr:    cfg.ListenAddr,
 		Handler: srv.Handler(),
 	}
+	httpServer.RegisterOnShutdown(srv.CloseStreams)
 
 	go func() {
 		log.Printf("Listening on %s", cfg.ListenAddr)
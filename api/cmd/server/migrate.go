@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"bot-manager/internal/db"
+)
+
+// runMigrate implements `botmanager migrate [up|status]`.
+func runMigrate(ctx context.Context, database *db.DB, migrations fs.FS, args []string) error {
+	cmd := "up"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+
+	switch cmd {
+	case "up":
+		if err := database.RunMigrations(ctx, migrations); err != nil {
+			return err
+		}
+		fmt.Println("Migrations are up to date.")
+		return nil
+
+	case "status":
+		states, err := database.MigrationStatus(ctx, migrations)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tSTATE\tAPPLIED AT\tCHECKSUM")
+		for _, st := range states {
+			appliedAt := "-"
+			if st.AppliedAt != nil {
+				appliedAt = st.AppliedAt.Local().Format(time.DateTime)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", st.Version, st.State, appliedAt, st.Checksum[:12])
+		}
+		return tw.Flush()
+
+	default:
+		return fmt.Errorf("unknown migrate command %q (want up or status)", cmd)
+	}
+}
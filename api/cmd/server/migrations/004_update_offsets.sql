@@ -0,0 +1,11 @@
+-- Persist the last processed Telegram update_id per bot so a restarted
+-- runner resumes exactly where it stopped instead of relying on Telegram's
+-- unconfirmed queue.
+CREATE TABLE IF NOT EXISTS bot_update_offsets (
+    bot_id     TEXT PRIMARY KEY REFERENCES bots(id) ON DELETE CASCADE,
+    update_id  BIGINT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Optional per-bot policy: skip updates older than N minutes (0 = disabled).
+ALTER TABLE bots ADD COLUMN IF NOT EXISTS stale_update_minutes INT NOT NULL DEFAULT 0;
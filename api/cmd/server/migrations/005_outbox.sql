@@ -0,0 +1,22 @@
+-- Durable outbound message queue. Every message a bot sends is written here
+-- first and delivered by the per-bot dispatcher, which retries transient
+-- failures, honours Telegram's retry_after and keeps per-chat order.
+-- Delivered items are deleted; items that keep failing are dead-lettered.
+CREATE TABLE IF NOT EXISTS bot_outbox (
+    id              BIGSERIAL PRIMARY KEY,
+    bot_id          TEXT NOT NULL REFERENCES bots(id) ON DELETE CASCADE,
+    chat_id         BIGINT NOT NULL,
+    kind            TEXT NOT NULL,
+    text            TEXT NOT NULL DEFAULT '',
+    file_key        TEXT NOT NULL DEFAULT '',
+    file_name       TEXT NOT NULL DEFAULT '',
+    reply_markup    JSONB,
+    status          TEXT NOT NULL DEFAULT 'pending',
+    attempts        INT NOT NULL DEFAULT 0,
+    last_error      TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS bot_outbox_pending_idx
+    ON bot_outbox(bot_id, chat_id, id) WHERE status = 'pending';
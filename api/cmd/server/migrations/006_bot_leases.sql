@@ -0,0 +1,12 @@
+-- Bot ownership for multi-replica deployments. A replica may run a bot only
+-- while it holds an unexpired lease; it renews the lease on a heartbeat and
+-- mirrors the runner status so other replicas can report it.
+CREATE TABLE IF NOT EXISTS bot_leases (
+    bot_id     TEXT PRIMARY KEY REFERENCES bots(id) ON DELETE CASCADE,
+    owner      TEXT NOT NULL,
+    owner_url  TEXT NOT NULL DEFAULT '',
+    status     TEXT NOT NULL DEFAULT '',
+    status_msg TEXT NOT NULL DEFAULT '',
+    expires_at TIMESTAMPTZ NOT NULL,
+    renewed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,3 @@
+-- Free-form tags for grouping bots in bulk operations.
+ALTER TABLE bots ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+CREATE INDEX IF NOT EXISTS bots_tags_idx ON bots USING GIN (tags);
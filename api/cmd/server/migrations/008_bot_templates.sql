@@ -0,0 +1,19 @@
+-- Reusable bot templates: messages, button text, assets and policies without
+-- a token or channel. Template objects live under templates/{id}/ in MinIO;
+-- assets lists the copied documents.
+CREATE TABLE IF NOT EXISTS bot_templates (
+    id                   TEXT PRIMARY KEY,
+    name                 TEXT NOT NULL DEFAULT '',
+    description          TEXT NOT NULL DEFAULT '',
+    type                 TEXT NOT NULL DEFAULT 'document-bot',
+    welcome_img_key      TEXT NOT NULL DEFAULT '',
+    welcome_msg          TEXT NOT NULL DEFAULT '',
+    button_text          TEXT NOT NULL DEFAULT '',
+    not_sub_msg          TEXT NOT NULL DEFAULT '',
+    success_msg          TEXT NOT NULL DEFAULT '',
+    stale_update_minutes INT NOT NULL DEFAULT 0,
+    tags                 TEXT[] NOT NULL DEFAULT '{}',
+    assets               JSONB NOT NULL DEFAULT '[]',
+    created_at           TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at           TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
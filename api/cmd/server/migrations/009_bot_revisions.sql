@@ -0,0 +1,10 @@
+-- Every create/update of a bot writes a revision with the full config
+-- snapshot (without the token), so edits can be inspected and rolled back.
+CREATE TABLE IF NOT EXISTS bot_revisions (
+    bot_id     TEXT NOT NULL REFERENCES bots(id) ON DELETE CASCADE,
+    rev        INT NOT NULL,
+    author     TEXT NOT NULL DEFAULT '',
+    snapshot   JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (bot_id, rev)
+);
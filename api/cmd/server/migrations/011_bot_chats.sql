@@ -0,0 +1,14 @@
+-- Chats a bot has seen: where it was added or removed (my_chat_member) and
+-- channels whose posts were forwarded to it. Used to pick a channel ID
+-- without knowing the numeric -100… form.
+CREATE TABLE IF NOT EXISTS bot_chats (
+    bot_id             TEXT NOT NULL REFERENCES bots(id) ON DELETE CASCADE,
+    chat_id            BIGINT NOT NULL,
+    type               TEXT NOT NULL DEFAULT '',
+    title              TEXT NOT NULL DEFAULT '',
+    username           TEXT NOT NULL DEFAULT '',
+    status             TEXT NOT NULL DEFAULT '',
+    forward_message_id INT NOT NULL DEFAULT 0,
+    updated_at         TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (bot_id, chat_id)
+);
@@ -0,0 +1,3 @@
+-- Per-bot Bot API server (e.g. a self-hosted telegram-bot-api); empty uses
+-- the global TELEGRAM_API_ENDPOINT.
+ALTER TABLE bots ADD COLUMN IF NOT EXISTS api_endpoint TEXT NOT NULL DEFAULT '';
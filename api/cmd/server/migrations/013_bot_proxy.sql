@@ -0,0 +1,3 @@
+-- Per-bot proxy for Telegram traffic; empty uses the global TELEGRAM_PROXY,
+-- 'direct' bypasses it.
+ALTER TABLE bots ADD COLUMN IF NOT EXISTS proxy TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,9 @@
+-- Accounts of the web UI. The admin from ADMIN_USERNAME and the stored
+-- admin_password_hash setting becomes the first owner on startup.
+CREATE TABLE IF NOT EXISTS users (
+    username      TEXT PRIMARY KEY,
+    password_hash TEXT NOT NULL,
+    role          TEXT NOT NULL CHECK (role IN ('owner', 'editor', 'operator', 'viewer')),
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,14 @@
+-- Login sessions. The cookie carries a random token; only its HMAC is
+-- stored, under a separate public id used to list and revoke sessions.
+CREATE TABLE IF NOT EXISTS sessions (
+    id           TEXT PRIMARY KEY,
+    token_hash   TEXT NOT NULL UNIQUE,
+    username     TEXT NOT NULL REFERENCES users(username) ON DELETE CASCADE,
+    ip           TEXT NOT NULL DEFAULT '',
+    user_agent   TEXT NOT NULL DEFAULT '',
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at   TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS sessions_username_idx ON sessions(username);
@@ -0,0 +1,5 @@
+-- Remember which Telegram bot (the "<id>:" part of the token) an update
+-- offset belongs to, so a token for another Telegram bot starts from scratch
+-- instead of skipping that bot's updates. Existing rows are filled in by the
+-- runner on its next update.
+ALTER TABLE bot_update_offsets ADD COLUMN IF NOT EXISTS token_bot_id TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"bot-manager/internal/db"
+)
+
+// ensureOwner creates the first owner if there are no users yet. An install
+// from before user accounts keeps its admin password (the
+// admin_password_hash setting); a fresh one gets ADMIN_USERNAME and
+// ADMIN_PASSWORD.
+func ensureOwner(ctx context.Context, database db.Store, username, password string) error {
+	users, err := database.GetUsers(ctx)
+	if err != nil {
+		return err
+	}
+	if len(users) > 0 {
+		return nil
+	}
+
+	hash, err := database.GetSetting(ctx, "admin_password_hash")
+	migrated := err == nil && hash != ""
+	if !migrated {
+		h, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return fmt.Errorf("bcrypt: %w", err)
+		}
+		hash = string(h)
+	}
+
+	err = database.CreateUser(ctx, db.User{Username: username, PasswordHash: hash, Role: db.RoleOwner})
+	if errors.Is(err, db.ErrUserExists) {
+		return nil // another replica was faster
+	}
+	if err != nil {
+		return err
+	}
+	if migrated {
+		log.Printf("Admin %q is now an owner account", username)
+	} else {
+		fmt.Printf("\n=== WebUI Credentials ===\nUsername: %s\nPassword: %s\n=========================\n\n",
+			username, password)
+	}
+	return nil
+}
+
+// runResetPassword implements `botmanager reset-password [username]` for
+// lockouts: it sets a random password for username (ADMIN_USERNAME by
+// default) and prints it. A user that does not exist is created as an owner.
+func runResetPassword(ctx context.Context, database db.Store, defaultUser string, args []string) error {
+	username := defaultUser
+	if len(args) > 0 {
+		username = args[0]
+	}
+	if len(args) > 1 || username == "" {
+		return fmt.Errorf("usage: reset-password [username]")
+	}
+
+	b := make([]byte, 15)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	password := base64.RawURLEncoding.EncodeToString(b)
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("bcrypt: %w", err)
+	}
+
+	u, err := database.GetUser(ctx, username)
+	if err == nil {
+		u.PasswordHash = string(hash)
+		err = database.UpdateUser(ctx, u)
+	} else {
+		u = db.User{Username: username, PasswordHash: string(hash), Role: db.RoleOwner}
+		err = database.CreateUser(ctx, u)
+	}
+	if err != nil {
+		return err
+	}
+	revoked, err := database.DeleteSessions(ctx, username, "")
+	if err != nil {
+		return fmt.Errorf("revoke sessions: %w", err)
+	}
+	database.RecordAudit(ctx, db.AuditUserPassword, username, "reset from the command line") //nolint:errcheck
+
+	fmt.Printf("Password of %s (%s) reset, %d session(s) revoked.\nNew password: %s\n", username, u.Role, revoked, password)
+	return nil
+}
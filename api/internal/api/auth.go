@@ -2,11 +2,18 @@ package api
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
 
 	"golang.org/x/crypto/bcrypt"
+
+	"bot-manager/internal/db"
+	"bot-manager/internal/validation"
 )
 
+// dummyHash is compared against when a login names an unknown user.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
 func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Username string `json:"username"`
@@ -17,45 +24,85 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Username != s.adminUsername {
+	u, err := s.database.GetUser(r.Context(), req.Username)
+	if err != nil {
+		// Spend the same time as for a wrong password, so the response does
+		// not tell which usernames exist.
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(req.Password)) //nolint:errcheck
 		http.Error(w, `{"error":"invalid credentials"}`, http.StatusUnauthorized)
 		return
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(s.passwordHash), []byte(req.Password)); err != nil {
+	if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(req.Password)); err != nil {
 		http.Error(w, `{"error":"invalid credentials"}`, http.StatusUnauthorized)
 		return
 	}
 
-	token := s.makeSessionToken(req.Username)
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session",
-		Value:    token,
-		HttpOnly: true,
-		SameSite: http.SameSiteStrictMode,
-		MaxAge:   86400,
-		Path:     "/",
-	})
+	if err := s.startSession(w, r, u.Username); err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.Write([]byte(`{"ok":true}`))
 }
 
+// handleLogout ends the current session, so its cookie stops working even if
+// it was copied.
 func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
-	http.SetCookie(w, &http.Cookie{
-		Name:   "session",
-		Value:  "",
-		MaxAge: -1,
-		Path:   "/",
-	})
+	if _, sess, ok := s.userFromRequest(r); ok {
+		if err := s.database.DeleteSession(r.Context(), sess.Username, sess.ID); err != nil {
+			log.Printf("Ошибка удаления сессии %s: %v", sess.ID, err)
+		}
+	}
+	clearSessionCookie(w)
 	w.Write([]byte(`{"ok":true}`))
 }
 
 func (s *Server) handleMe(w http.ResponseWriter, r *http.Request) {
-	username := s.usernameFromRequest(r)
-	if username == "" {
+	u, _, ok := s.userFromRequest(r)
+	if !ok {
 		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"username": username})
+	json.NewEncoder(w).Encode(map[string]string{"username": u.Username, "role": string(u.Role)})
+}
+
+// handleChangePassword changes the logged-in user's password. Logins check
+// the users table, so the new password applies at once, on every replica.
+// The user's other sessions are revoked.
+// POST /api/auth/password {"current_password", "new_password"}
+func (s *Server) handleChangePassword(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+	u := userFrom(r)
+	if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(req.CurrentPassword)); err != nil {
+		jsonError(w, "current password is incorrect", http.StatusForbidden)
+		return
+	}
+	if err := validation.Password("new_password", req.NewPassword, u.Username); err != nil {
+		managerError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	u.PasswordHash = string(hash)
+	if err := s.database.UpdateUser(r.Context(), u); err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.revokeSessions(r.Context(), u.Username, sessionFrom(r).ID)
+	s.database.RecordAudit(r.Context(), db.AuditUserPassword, u.Username, "changed by the user") //nolint:errcheck
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"ok":true}`))
 }
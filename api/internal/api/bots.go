@@ -2,11 +2,14 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strings"
 	"time"
 
 	"bot-manager/internal/db"
+	"bot-manager/internal/manager"
+	"bot-manager/internal/validation"
 )
 
 // botDetail extends Bot with a presigned URL for the welcome image.
@@ -27,23 +30,23 @@ func (s *Server) handleCreateBot(w http.ResponseWriter, r *http.Request) {
 		jsonError(w, "invalid body", http.StatusBadRequest)
 		return
 	}
-	if bot.ID == "" || bot.Token == "" {
-		jsonError(w, "id and token are required", http.StatusBadRequest)
+	if !s.verifyBeforeSave(w, r, bot) {
 		return
 	}
 
 	if err := s.mgr.AddBot(r.Context(), bot); err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		managerError(w, err, http.StatusInternalServerError)
 		return
 	}
 
 	if r.URL.Query().Get("start") == "1" {
-		s.mgr.Start(bot.ID)
+		s.mgr.Start(r.Context(), bot.ID)
 	}
 
+	created, _ := s.database.GetBot(r.Context(), bot.ID)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(bot)
+	json.NewEncoder(w).Encode(s.mgr.Redact(created))
 }
 
 func (s *Server) handleGetBot(w http.ResponseWriter, r *http.Request) {
@@ -53,32 +56,49 @@ func (s *Server) handleGetBot(w http.ResponseWriter, r *http.Request) {
 		jsonError(w, err.Error(), http.StatusNotFound)
 		return
 	}
-	resp := botDetail{Bot: bot}
+	resp := botDetail{Bot: s.mgr.Redact(bot)}
 	if bot.WelcomeImgKey != "" {
 		u, _ := s.minio.PresignURL(r.Context(), bot.WelcomeImgKey, time.Hour)
 		resp.WelcomeImgURL = u
 	}
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", botETag(bot))
 	json.NewEncoder(w).Encode(resp)
 }
 
+// handleUpdateBot replaces the whole config of a bot. Like PATCH, it honours
+// If-Match.
 func (s *Server) handleUpdateBot(w http.ResponseWriter, r *http.Request) {
 	id := botIDFromPath(r)
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	var bot db.Bot
 	if err := json.NewDecoder(r.Body).Decode(&bot); err != nil {
 		jsonError(w, "invalid body", http.StatusBadRequest)
 		return
 	}
 	bot.ID = id
+	if !s.verifyBeforeSave(w, r, bot) {
+		return
+	}
 
-	if err := s.mgr.UpdateBot(r.Context(), bot); err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+	if version.IsZero() {
+		err = s.mgr.UpdateBot(r.Context(), bot)
+	} else {
+		err = s.mgr.UpdateBotIfMatch(r.Context(), bot, version)
+	}
+	if err != nil {
+		managerError(w, err, http.StatusInternalServerError)
 		return
 	}
 
 	updated, _ := s.database.GetBot(r.Context(), id)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(updated)
+	w.Header().Set("ETag", botETag(updated))
+	json.NewEncoder(w).Encode(s.mgr.Redact(updated))
 }
 
 func (s *Server) handleDeleteBot(w http.ResponseWriter, r *http.Request) {
@@ -92,8 +112,8 @@ func (s *Server) handleDeleteBot(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleStartBot(w http.ResponseWriter, r *http.Request) {
 	id := botIDFromPath(r)
-	if err := s.mgr.Start(id); err != nil {
-		jsonError(w, err.Error(), http.StatusBadRequest)
+	if err := s.mgr.Start(r.Context(), id); err != nil {
+		managerError(w, err, http.StatusBadRequest)
 		return
 	}
 	w.Write([]byte(`{"ok":true}`))
@@ -101,8 +121,8 @@ func (s *Server) handleStartBot(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleStopBot(w http.ResponseWriter, r *http.Request) {
 	id := botIDFromPath(r)
-	if err := s.mgr.Stop(id); err != nil {
-		jsonError(w, err.Error(), http.StatusBadRequest)
+	if err := s.mgr.Stop(r.Context(), id); err != nil {
+		managerError(w, err, http.StatusBadRequest)
 		return
 	}
 	w.Write([]byte(`{"ok":true}`))
@@ -110,8 +130,8 @@ func (s *Server) handleStopBot(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleRestartBot(w http.ResponseWriter, r *http.Request) {
 	id := botIDFromPath(r)
-	if err := s.mgr.Restart(id); err != nil {
-		jsonError(w, err.Error(), http.StatusBadRequest)
+	if err := s.mgr.Restart(r.Context(), id); err != nil {
+		managerError(w, err, http.StatusBadRequest)
 		return
 	}
 	w.Write([]byte(`{"ok":true}`))
@@ -138,6 +158,81 @@ func botIDFromPath(r *http.Request) string {
 	return parts[0]
 }
 
+// handleCloneBot copies a bot, its documents and welcome image under a new ID.
+// The clone is created disabled.
+func (s *Server) handleCloneBot(w http.ResponseWriter, r *http.Request) {
+	var p manager.CloneParams
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		jsonError(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	bot, err := s.mgr.CloneBot(r.Context(), botIDFromPath(r), p)
+	if err != nil {
+		managerError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(s.mgr.Redact(bot))
+}
+
+// handleRevealToken returns a bot's token in plaintext. Every reveal is
+// written to the audit log.
+func (s *Server) handleRevealToken(w http.ResponseWriter, r *http.Request) {
+	id := botIDFromPath(r)
+	bot, err := s.database.GetBot(r.Context(), id)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	token, err := s.mgr.PlainToken(bot)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := s.database.RecordAudit(r.Context(), db.AuditTokenReveal, id, r.RemoteAddr); err != nil {
+		// No audit record, no token.
+		jsonError(w, "audit: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"token": token})
+}
+
+// managerError writes an error returned by the manager. Validation failures
+// are reported as 422 with the individual field errors.
+func managerError(w http.ResponseWriter, err error, def int) {
+	var verrs validation.Errors
+	if errors.As(err, &verrs) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":  err.Error(),
+			"errors": verrs,
+		})
+		return
+	}
+	jsonError(w, err.Error(), managerErrorStatus(err, def))
+}
+
+// managerErrorStatus maps manager errors to HTTP status codes, falling back
+// to def for errors without a more specific meaning.
+func managerErrorStatus(err error, def int) int {
+	var notOwner *manager.NotOwnerError
+	if errors.Is(err, manager.ErrTokenConflict) || errors.Is(err, manager.ErrBotExists) || errors.As(err, &notOwner) ||
+		errors.Is(err, db.ErrUserExists) || errors.Is(err, db.ErrLastOwner) {
+		return http.StatusConflict
+	}
+	if errors.Is(err, db.ErrStale) {
+		return http.StatusPreconditionFailed
+	}
+	if errors.Is(err, db.ErrNotFound) {
+		return http.StatusNotFound
+	}
+	return def
+}
+
 func jsonError(w http.ResponseWriter, msg string, code int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
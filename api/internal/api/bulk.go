@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"bot-manager/internal/db"
+	"bot-manager/internal/manager"
+)
+
+type bulkRequest struct {
+	Action manager.BulkAction `json:"action"`
+	IDs    []string           `json:"ids"`
+	Tag    string             `json:"tag"`
+}
+
+// handleBulk applies one action to many bots, selected by ID or by tag.
+// Lifecycle actions on bots running on another replica are forwarded to it.
+func (s *Server) handleBulk(w http.ResponseWriter, r *http.Request) {
+	var req bulkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+	if !req.Action.Valid() {
+		jsonError(w, fmt.Sprintf("unknown action %q", req.Action), http.StatusBadRequest)
+		return
+	}
+	need := db.RoleEditor
+	if isLifecycle(req.Action) {
+		need = db.RoleOperator
+	}
+	if !userFrom(r).Role.Includes(need) {
+		jsonError(w, fmt.Sprintf("forbidden: %s requires the %s role", req.Action, need), http.StatusForbidden)
+		return
+	}
+	ids, err := s.mgr.SelectBots(r.Context(), req.IDs, req.Tag)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results := make([]manager.BulkResult, len(ids))
+	var local []string
+	var localIdx []int
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		lease, remote := db.Lease{}, false
+		if isLifecycle(req.Action) && r.Header.Get(forwardedHeader) == "" {
+			lease, remote = s.mgr.RemoteOwner(r.Context(), id)
+		}
+		if !remote {
+			local = append(local, id)
+			localIdx = append(localIdx, i)
+			continue
+		}
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			results[i] = manager.BulkResult{ID: id, OK: true}
+			if err := s.forwardLifecycle(r, lease, id, req.Action); err != nil {
+				results[i] = manager.BulkResult{ID: id, Error: err.Error()}
+			}
+		}(i, id)
+	}
+	for j, res := range s.mgr.Bulk(r.Context(), req.Action, local) {
+		results[localIdx[j]] = res
+	}
+	wg.Wait()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+}
+
+func isLifecycle(a manager.BulkAction) bool {
+	return a == manager.BulkStart || a == manager.BulkStop || a == manager.BulkRestart
+}
+
+// forwardLifecycle calls POST /api/bots/{id}/{action} on the owning replica
+// with the caller's credentials.
+func (s *Server) forwardLifecycle(r *http.Request, lease db.Lease, id string, action manager.BulkAction) error {
+	if lease.OwnerURL == "" {
+		return fmt.Errorf("bot is running on replica %q, which has no reachable INSTANCE_URL", lease.Owner)
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	url := strings.TrimRight(lease.OwnerURL, "/") + "/api/bots/" + id + "/" + string(action)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		return err
+	}
+	for _, c := range r.Cookies() {
+		req.AddCookie(c)
+	}
+	req.Header.Set(forwardedHeader, "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("replica %q: %w", lease.Owner, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	var body struct {
+		Error string `json:"error"`
+	}
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if json.Unmarshal(raw, &body) == nil && body.Error != "" {
+		return fmt.Errorf("replica %q: %s", lease.Owner, body.Error)
+	}
+	return fmt.Errorf("replica %q: %s", lease.Owner, resp.Status)
+}
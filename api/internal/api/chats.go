@@ -0,0 +1,50 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// handleResolveChat turns "@channel", a t.me link or the ID of a private
+// message with a forwarded post into a channel ID and title.
+// POST /api/bots/resolve-chat
+func (s *Server) handleResolveChat(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		ID                 string `json:"id"`
+		Token              string `json:"token"`
+		Chat               string `json:"chat"`
+		ForwardedMessageID int    `json:"forwarded_message_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		jsonError(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+	if body.Chat == "" && body.ForwardedMessageID == 0 {
+		jsonError(w, "chat or forwarded_message_id is required", http.StatusBadRequest)
+		return
+	}
+	chat, err := s.mgr.ResolveChat(r.Context(), body.ID, body.Token, body.Chat, body.ForwardedMessageID)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(chat)
+}
+
+// handleListBotChats lists the chats where the bot is an administrator.
+// GET /api/bots/{id}/chats
+func (s *Server) handleListBotChats(w http.ResponseWriter, r *http.Request) {
+	id := botIDFromPath(r)
+	if _, err := s.database.GetBot(r.Context(), id); err != nil {
+		jsonError(w, "not found", http.StatusNotFound)
+		return
+	}
+	chats, err := s.mgr.AdminChats(r.Context(), id)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(chats)
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"bot-manager/internal/botrunner"
+)
+
+// sseKeepAlive is how often an idle stream sends a comment line so proxies
+// don't close it.
+const sseKeepAlive = 25 * time.Second
+
+// startSSE prepares w for a Server-Sent Events stream.
+func startSSE(w http.ResponseWriter) (http.Flusher, bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		jsonError(w, "streaming not supported", http.StatusInternalServerError)
+		return nil, false
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	return flusher, true
+}
+
+func writeSSE(w http.ResponseWriter, event string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
+
+// handleEvents streams bot status, log and user events as SSE.
+// The first event is a "snapshot" with the current status list.
+// With ?bot= the stream comes from the replica running that bot. Without it
+// only this replica's events follow the snapshot, so clients poll
+// GET /api/bots for bots owned by other replicas (see Owner).
+// GET /api/events?bot={id}
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	botID := r.URL.Query().Get("bot")
+	if botID != "" && s.proxyToOwner(w, r, botID) {
+		return
+	}
+
+	sub := s.mgr.Events().Subscribe(botID)
+	defer s.mgr.Events().Unsubscribe(sub)
+
+	flusher, ok := startSSE(w)
+	if !ok {
+		return
+	}
+
+	snapshot := s.mgr.Status()
+	if botID != "" {
+		filtered := snapshot[:0]
+		for _, b := range snapshot {
+			if b.ID == botID {
+				filtered = append(filtered, b)
+			}
+		}
+		snapshot = filtered
+	}
+	if writeSSE(w, "snapshot", snapshot) != nil {
+		return
+	}
+	flusher.Flush()
+
+	s.streamEvents(w, r, flusher, sub.C, nil)
+}
+
+// handleLogStream sends the buffered log of a bot and then follows it live.
+// GET /api/bots/{id}/logs/stream
+func (s *Server) handleLogStream(w http.ResponseWriter, r *http.Request) {
+	id := botIDFromPath(r)
+
+	// Subscribe before reading the backlog so no line falls in between.
+	sub := s.mgr.Events().Subscribe(id)
+	defer s.mgr.Events().Unsubscribe(sub)
+
+	lines, err := s.mgr.Logs(id)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	flusher, ok := startSSE(w)
+	if !ok {
+		return
+	}
+	for _, line := range lines {
+		if writeSSE(w, "log", line) != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	s.streamEvents(w, r, flusher, sub.C, func(e botrunner.Event) (string, interface{}, bool) {
+		return "log", e.Line, e.Type == botrunner.EventLog
+	})
+}
+
+// streamEvents writes events until the client goes away. format, if set,
+// chooses the SSE event name and payload and may skip events.
+func (s *Server) streamEvents(
+	w http.ResponseWriter,
+	r *http.Request,
+	flusher http.Flusher,
+	events <-chan botrunner.Event,
+	format func(botrunner.Event) (string, interface{}, bool),
+) {
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.streamsDone:
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case e := <-events:
+			name, payload, ok := string(e.Type), interface{}(e), true
+			if format != nil {
+				name, payload, ok = format(e)
+			}
+			if !ok {
+				continue
+			}
+			if writeSSE(w, name, payload) != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
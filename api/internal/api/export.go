@@ -3,6 +3,7 @@ package api
 import (
 	"archive/zip"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -11,47 +12,174 @@ import (
 	"time"
 
 	"bot-manager/internal/db"
+	"bot-manager/internal/validation"
 )
 
 // exportPayload is the canonical import/export format.
 // It is intentionally compatible with the legacy bots.json format.
 type exportPayload struct {
-	Version    int      `json:"version"`
-	ExportedAt string   `json:"exported_at"`
-	Bots       []db.Bot `json:"bots"`
+	Version    int           `json:"version"`
+	ExportedAt string        `json:"exported_at"`
+	Bots       []db.Bot      `json:"bots"`
+	Templates  []db.Template `json:"templates,omitempty"`
 }
 
 // importBot mirrors the legacy bots.json shape (extra fields are ignored).
 type importBot struct {
-	ID         string `json:"id"`
-	Name       string `json:"name"`
-	Type       string `json:"type"`
-	Token      string `json:"token"`
-	ChannelID  int64  `json:"channel_id"`
-	InviteLink string `json:"invite_link"`
-	WelcomeMsg string `json:"welcome_msg"`
-	ButtonText string `json:"button_text"`
-	NotSubMsg  string `json:"not_sub_msg"`
-	SuccessMsg string `json:"success_msg"`
-	Enabled    bool   `json:"enabled"`
+	ID                 string   `json:"id"`
+	Name               string   `json:"name"`
+	Type               string   `json:"type"`
+	Token              string   `json:"token"`
+	ChannelID          int64    `json:"channel_id"`
+	InviteLink         string   `json:"invite_link"`
+	WelcomeMsg         string   `json:"welcome_msg"`
+	ButtonText         string   `json:"button_text"`
+	NotSubMsg          string   `json:"not_sub_msg"`
+	SuccessMsg         string   `json:"success_msg"`
+	Enabled            bool     `json:"enabled"`
+	StaleUpdateMinutes int      `json:"stale_update_minutes"`
+	Tags               []string `json:"tags"`
+	APIEndpoint        string   `json:"api_endpoint"`
+	Proxy              string   `json:"proxy"`
 	// Legacy fields — present in old bots.json, silently ignored.
 	AssetsDir  string `json:"assets_dir,omitempty"`
 	WelcomeImg string `json:"welcome_img,omitempty"`
 }
 
-// handleExportJSON exports all bot configs as a JSON file.
-// GET /api/export
-func (s *Server) handleExportJSON(w http.ResponseWriter, r *http.Request) {
-	bots, err := s.database.GetAllBots(r.Context())
+// toBot converts an imported entry into a bot config. Imported bots are never
+// auto-enabled.
+func (ib importBot) toBot() db.Bot {
+	return db.Bot{
+		ID:                 ib.ID,
+		Name:               ib.Name,
+		Type:               db.BotType(ib.Type),
+		Token:              ib.Token,
+		ChannelID:          ib.ChannelID,
+		InviteLink:         ib.InviteLink,
+		WelcomeMsg:         ib.WelcomeMsg,
+		ButtonText:         ib.ButtonText,
+		NotSubMsg:          ib.NotSubMsg,
+		SuccessMsg:         ib.SuccessMsg,
+		Enabled:            false,
+		StaleUpdateMinutes: ib.StaleUpdateMinutes,
+		Tags:               ib.Tags,
+		APIEndpoint:        ib.APIEndpoint,
+		Proxy:              ib.Proxy,
+	}
+}
+
+// parseImport decodes an import body in either the wrapped
+// { "bots": [...], "templates": [...] } format or as a legacy flat array.
+func parseImport(body []byte) ([]importBot, []db.Template, error) {
+	var wrapped struct {
+		Bots      []importBot   `json:"bots"`
+		Templates []db.Template `json:"templates"`
+	}
+	if err := json.Unmarshal(body, &wrapped); err == nil && (len(wrapped.Bots) > 0 || len(wrapped.Templates) > 0) {
+		return wrapped.Bots, wrapped.Templates, nil
+	}
+	var bots []importBot
+	if err := json.Unmarshal(body, &bots); err != nil {
+		return nil, nil, err
+	}
+	return bots, nil, nil
+}
+
+// importBots adds or replaces the imported bots. IDs and tokens are trimmed
+// the same way for JSON and ZIP imports.
+func (s *Server) importBots(ctx context.Context, bots []importBot) (imported, errs []string) {
+	for _, ib := range bots {
+		ib.ID = strings.TrimSpace(ib.ID)
+		ib.Token = strings.TrimSpace(ib.Token)
+		if ib.ID == "" {
+			errs = append(errs, fmt.Sprintf("%q: id is required", ib.Name))
+			continue
+		}
+		if err := s.mgr.AddBot(ctx, ib.toBot()); err != nil {
+			errs = append(errs, fmt.Sprintf("%q: %v", ib.ID, err))
+			continue
+		}
+		imported = append(imported, ib.ID)
+	}
+	return imported, errs
+}
+
+// importTemplates stores imported templates. Without the archive's objects
+// the welcome image and documents would dangle, so withObjects=false drops
+// them.
+func (s *Server) importTemplates(ctx context.Context, templates []db.Template, withObjects bool) (imported, errs []string) {
+	for _, t := range templates {
+		t.ID = strings.TrimSpace(t.ID)
+		if t.ID == "" {
+			errs = append(errs, fmt.Sprintf("template %q: id is required", t.Name))
+			continue
+		}
+		// Template IDs become MinIO prefixes.
+		if !validation.ValidID(t.ID) {
+			errs = append(errs, fmt.Sprintf("template %q: id may contain only letters, digits, '-' and '_' (up to 64 characters)", t.ID))
+			continue
+		}
+		if !withObjects {
+			t.WelcomeImgKey = ""
+			t.Assets = nil
+		}
+		if err := s.database.UpsertTemplate(ctx, t); err != nil {
+			errs = append(errs, fmt.Sprintf("template %q: %v", t.ID, err))
+			continue
+		}
+		imported = append(imported, t.ID)
+	}
+	return imported, errs
+}
+
+// exportPayloadFor loads everything that goes into an export. Tokens are
+// exported in plaintext so the export can be imported anywhere, unless
+// ?include_tokens=false, which also masks proxy passwords; exporting them is
+// audited. A token that cannot be decrypted fails the export rather than
+// being exported empty.
+func (s *Server) exportPayloadFor(r *http.Request) (exportPayload, error) {
+	ctx := r.Context()
+	bots, err := s.database.GetAllBots(ctx)
 	if err != nil {
-		jsonError(w, "db: "+err.Error(), http.StatusInternalServerError)
-		return
+		return exportPayload{}, err
+	}
+	includeTokens := r.URL.Query().Get("include_tokens") != "false"
+	for i := range bots {
+		token := ""
+		if includeTokens {
+			if token, err = s.mgr.PlainToken(bots[i]); err != nil {
+				return exportPayload{}, fmt.Errorf("bot %q: %w (export with include_tokens=false to leave tokens out)", bots[i].ID, err)
+			}
+		} else {
+			bots[i].Proxy = db.RedactProxy(bots[i].Proxy)
+		}
+		bots[i].Token = token
+	}
+	if includeTokens && len(bots) > 0 {
+		if err := s.database.RecordAudit(ctx, db.AuditTokenExport, "", fmt.Sprintf("%d bots", len(bots))); err != nil {
+			return exportPayload{}, fmt.Errorf("audit: %w", err)
+		}
 	}
 
-	payload := exportPayload{
+	templates, err := s.database.GetTemplates(ctx)
+	if err != nil {
+		return exportPayload{}, err
+	}
+	return exportPayload{
 		Version:    1,
 		ExportedAt: time.Now().UTC().Format(time.RFC3339),
 		Bots:       bots,
+		Templates:  templates,
+	}, nil
+}
+
+// handleExportJSON exports all bot configs as a JSON file.
+// GET /api/export
+func (s *Server) handleExportJSON(w http.ResponseWriter, r *http.Request) {
+	payload, err := s.exportPayloadFor(r)
+	if err != nil {
+		jsonError(w, "export: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -66,9 +194,9 @@ func (s *Server) handleExportJSON(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleExportZIP(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	bots, err := s.database.GetAllBots(ctx)
+	payload, err := s.exportPayloadFor(r)
 	if err != nil {
-		jsonError(w, "db: "+err.Error(), http.StatusInternalServerError)
+		jsonError(w, "export: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -79,11 +207,6 @@ func (s *Server) handleExportZIP(w http.ResponseWriter, r *http.Request) {
 	defer zw.Close()
 
 	// Write bots.json
-	payload := exportPayload{
-		Version:    1,
-		ExportedAt: time.Now().UTC().Format(time.RFC3339),
-		Bots:       bots,
-	}
 	jsonBytes, _ := json.MarshalIndent(payload, "", "  ")
 	jf, err := zw.Create("bots.json")
 	if err == nil {
@@ -91,16 +214,10 @@ func (s *Server) handleExportZIP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Write assets for each bot.
-	for _, bot := range bots {
+	for _, bot := range payload.Bots {
 		// Welcome image
 		if bot.WelcomeImgKey != "" {
-			rc, _, err := s.minio.GetObject(ctx, bot.WelcomeImgKey)
-			if err == nil {
-				if f, err := zw.Create("assets/" + bot.WelcomeImgKey); err == nil {
-					io.Copy(f, rc) //nolint:errcheck
-				}
-				rc.Close()
-			}
+			s.zipObject(ctx, zw, bot.WelcomeImgKey)
 		}
 
 		// Documents
@@ -110,20 +227,42 @@ func (s *Server) handleExportZIP(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 		for _, obj := range objects {
-			rc, _, err := s.minio.GetObject(ctx, obj.Key)
-			if err != nil {
-				continue
-			}
-			if f, err := zw.Create("assets/" + obj.Key); err == nil {
-				io.Copy(f, rc) //nolint:errcheck
-			}
-			rc.Close()
+			s.zipObject(ctx, zw, obj.Key)
+		}
+	}
+
+	// Template objects (templates/{id}/...).
+	for _, t := range payload.Templates {
+		if t.WelcomeImgKey != "" {
+			s.zipObject(ctx, zw, t.WelcomeImgKey)
+		}
+		for _, a := range t.Assets {
+			s.zipObject(ctx, zw, a.MinioKey)
 		}
 	}
 }
 
+// zipObject writes the MinIO object at key to the archive as assets/{key}.
+// Missing objects are skipped.
+func (s *Server) zipObject(ctx context.Context, zw *zip.Writer, key string) {
+	rc, _, err := s.minio.GetObject(ctx, key)
+	if err != nil {
+		return
+	}
+	defer rc.Close()
+	if f, err := zw.Create("assets/" + key); err == nil {
+		io.Copy(f, rc) //nolint:errcheck
+	}
+}
+
 // handleExport routes between JSON and ZIP export based on ?format= query param.
+// ?include_tokens=false leaves the bot tokens out; only owners may export
+// them.
 func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("include_tokens") != "false" && !userFrom(r).Role.Includes(db.RoleOwner) {
+		jsonError(w, "forbidden: exporting tokens requires the owner role; use ?include_tokens=false", http.StatusForbidden)
+		return
+	}
 	if r.URL.Query().Get("format") == "zip" {
 		s.handleExportZIP(w, r)
 		return
@@ -131,11 +270,13 @@ func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
 	s.handleExportJSON(w, r)
 }
 
-// handleImport imports bots from a JSON body.
+// handleImport imports bots and templates from a JSON body.
 // Accepts both:
-//   - { "bots": [...] }  (new format)
+//   - { "bots": [...], "templates": [...] }  (new format)
 //   - [...]              (flat array, legacy)
 //
+// An empty token keeps the token of an existing bot (redacted exports).
+//
 // POST /api/import
 func (s *Server) handleImport(w http.ResponseWriter, r *http.Request) {
 	body, err := io.ReadAll(io.LimitReader(r.Body, 10<<20))
@@ -144,70 +285,38 @@ func (s *Server) handleImport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var importBots []importBot
-
-	// Try wrapped format first.
-	var wrapped struct {
-		Bots []importBot `json:"bots"`
-	}
-	if err := json.Unmarshal(body, &wrapped); err == nil && len(wrapped.Bots) > 0 {
-		importBots = wrapped.Bots
-	} else {
-		// Try flat array.
-		if err := json.Unmarshal(body, &importBots); err != nil {
-			jsonError(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
-			return
-		}
+	importBots, importTemplates, err := parseImport(body)
+	if err != nil {
+		jsonError(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	var imported []string
-	var errs []string
-
-	for _, ib := range importBots {
-		ib.ID = strings.TrimSpace(ib.ID)
-		ib.Token = strings.TrimSpace(ib.Token)
-
-		if ib.ID == "" || ib.Token == "" {
-			errs = append(errs, fmt.Sprintf("%q: id and token are required", ib.Name))
-			continue
-		}
-
-		bot := db.Bot{
-			ID:         ib.ID,
-			Name:       ib.Name,
-			Type:       db.BotType(ib.Type),
-			Token:      ib.Token,
-			ChannelID:  ib.ChannelID,
-			InviteLink: ib.InviteLink,
-			WelcomeMsg: ib.WelcomeMsg,
-			ButtonText: ib.ButtonText,
-			NotSubMsg:  ib.NotSubMsg,
-			SuccessMsg: ib.SuccessMsg,
-			Enabled:    false, // never auto-enable on import
-		}
-
-		if err := s.mgr.AddBot(r.Context(), bot); err != nil {
-			errs = append(errs, fmt.Sprintf("%q: %v", ib.ID, err))
-			continue
-		}
-		imported = append(imported, ib.ID)
-	}
+	imported, errs := s.importBots(r.Context(), importBots)
+	templates, tplErrs := s.importTemplates(r.Context(), importTemplates, false)
+	writeImportResult(w, imported, templates, append(errs, tplErrs...))
+}
 
+func writeImportResult(w http.ResponseWriter, imported, templates, errs []string) {
 	if imported == nil {
 		imported = []string{}
 	}
+	if templates == nil {
+		templates = []string{}
+	}
 	if errs == nil {
 		errs = []string{}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"imported": imported,
-		"errors":   errs,
+		"imported":  imported,
+		"templates": templates,
+		"errors":    errs,
 	})
 }
 
-// handleImportZIP imports bots and files from a ZIP archive.
+// handleImportZIP imports bots and files from a ZIP archive. Bots are
+// imported as by handleImport.
 // POST /api/import/zip  (multipart: field "file")
 func (s *Server) handleImportZIP(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseMultipartForm(200 << 20); err != nil {
@@ -236,6 +345,7 @@ func (s *Server) handleImportZIP(w http.ResponseWriter, r *http.Request) {
 
 	// Find and parse bots.json.
 	var importBots []importBot
+	var importTemplates []db.Template
 	for _, zf := range zr.File {
 		if zf.Name != "bots.json" {
 			continue
@@ -245,51 +355,20 @@ func (s *Server) handleImportZIP(w http.ResponseWriter, r *http.Request) {
 			jsonError(w, "open bots.json: "+err.Error(), http.StatusBadRequest)
 			return
 		}
-		var wrapped struct {
-			Bots []importBot `json:"bots"`
-		}
 		body, _ := io.ReadAll(rc)
 		rc.Close()
-		if err := json.Unmarshal(body, &wrapped); err == nil && len(wrapped.Bots) > 0 {
-			importBots = wrapped.Bots
-		} else {
-			json.Unmarshal(body, &importBots) //nolint:errcheck
-		}
+		importBots, importTemplates, _ = parseImport(body)
 		break
 	}
 
-	if len(importBots) == 0 {
+	if len(importBots) == 0 && len(importTemplates) == 0 {
 		jsonError(w, "bots.json not found or empty in archive", http.StatusBadRequest)
 		return
 	}
 
-	// Import bot configs.
-	var imported []string
-	var errs []string
-	for _, ib := range importBots {
-		if ib.ID == "" || ib.Token == "" {
-			errs = append(errs, fmt.Sprintf("%q: id and token are required", ib.Name))
-			continue
-		}
-		bot := db.Bot{
-			ID:         ib.ID,
-			Name:       ib.Name,
-			Type:       db.BotType(ib.Type),
-			Token:      ib.Token,
-			ChannelID:  ib.ChannelID,
-			InviteLink: ib.InviteLink,
-			WelcomeMsg: ib.WelcomeMsg,
-			ButtonText: ib.ButtonText,
-			NotSubMsg:  ib.NotSubMsg,
-			SuccessMsg: ib.SuccessMsg,
-			Enabled:    false,
-		}
-		if err := s.mgr.AddBot(r.Context(), bot); err != nil {
-			errs = append(errs, fmt.Sprintf("%q: %v", ib.ID, err))
-			continue
-		}
-		imported = append(imported, ib.ID)
-	}
+	imported, errs := s.importBots(r.Context(), importBots)
+	templates, tplErrs := s.importTemplates(r.Context(), importTemplates, true)
+	errs = append(errs, tplErrs...)
 
 	// Upload assets from the archive.
 	ctx := r.Context()
@@ -313,6 +392,11 @@ func (s *Server) handleImportZIP(w http.ResponseWriter, r *http.Request) {
 		contentType := http.DetectContentType(fileData)
 		s.minio.Upload(ctx, minioKey, contentType, bytes.NewReader(fileData), int64(len(fileData))) //nolint:errcheck
 
+		// Template objects are listed in the template itself.
+		if strings.HasPrefix(minioKey, "templates/") {
+			continue
+		}
+
 		// If it's a welcome image, update the DB record.
 		if strings.Contains(minioKey, "/welcome/") {
 			parts := strings.SplitN(minioKey, "/", 2)
@@ -339,16 +423,5 @@ func (s *Server) handleImportZIP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if imported == nil {
-		imported = []string{}
-	}
-	if errs == nil {
-		errs = []string{}
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"imported": imported,
-		"errors":   errs,
-	})
+	writeImportResult(w, imported, templates, errs)
 }
@@ -1,76 +1,92 @@
 package api
 
 import (
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
+	"context"
 	"fmt"
 	"net/http"
-	"strconv"
-	"strings"
-	"time"
+	"net/http/httputil"
+	"net/url"
+
+	"bot-manager/internal/db"
 )
 
-func (s *Server) makeSessionToken(username string) string {
-	expiry := time.Now().Add(24 * time.Hour).Unix()
-	payload := fmt.Sprintf("%s:%d", username, expiry)
-	mac := hmac.New(sha256.New, s.sessionSecret)
-	mac.Write([]byte(payload))
-	sig := hex.EncodeToString(mac.Sum(nil))
-	return payload + "|" + sig
+// authMiddleware is a chi-compatible middleware (func(http.Handler) http.Handler).
+// The session and user are loaded on every request, so revoked sessions, role
+// changes and deleted accounts take effect immediately.
+func (s *Server) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, sess, ok := s.userFromRequest(r)
+		if !ok {
+			w.Header().Set("Content-Type", "application/json")
+			http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		// Changes made by this request are attributed to the user.
+		ctx := context.WithValue(db.WithActor(r.Context(), u.Username), userKey{}, u)
+		ctx = withSession(ctx, sess)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
 }
 
-func (s *Server) validSessionToken(token string) (string, bool) {
-	parts := strings.SplitN(token, "|", 2)
-	if len(parts) != 2 {
-		return "", false
+// requireRole lets a request through only if the user's role includes role.
+// It goes after authMiddleware.
+func requireRole(role db.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !userFrom(r).Role.Includes(role) {
+				jsonError(w, fmt.Sprintf("forbidden: requires the %s role", role), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
 	}
-	payload, sig := parts[0], parts[1]
+}
 
-	mac := hmac.New(sha256.New, s.sessionSecret)
-	mac.Write([]byte(payload))
-	expected := hex.EncodeToString(mac.Sum(nil))
-	if !hmac.Equal([]byte(sig), []byte(expected)) {
-		return "", false
-	}
+type userKey struct{}
 
-	// payload format: "username:unixExpiry"
-	idx := strings.LastIndex(payload, ":")
-	if idx < 0 {
-		return "", false
-	}
-	username := payload[:idx]
-	expiryStr := payload[idx+1:]
-	expiry, err := strconv.ParseInt(expiryStr, 10, 64)
-	if err != nil || time.Now().Unix() > expiry {
-		return "", false
-	}
-	return username, true
+// userFrom returns the user authenticated by authMiddleware.
+func userFrom(r *http.Request) db.User {
+	u, _ := r.Context().Value(userKey{}).(db.User)
+	return u
 }
 
-// authMiddleware is a chi-compatible middleware (func(http.Handler) http.Handler).
-func (s *Server) authMiddleware(next http.Handler) http.Handler {
+// forwardedHeader marks requests already forwarded by another replica so they
+// are never bounced around between replicas.
+const forwardedHeader = "X-Bot-Manager-Forwarded"
+
+// routeToOwner forwards bot requests to the replica holding the bot's lease,
+// so start/stop/logs act on the runner that actually polls Telegram.
+func (s *Server) routeToOwner(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		cookie, err := r.Cookie("session")
-		if err != nil {
-			w.Header().Set("Content-Type", "application/json")
-			http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
-			return
-		}
-		if _, ok := s.validSessionToken(cookie.Value); !ok {
-			w.Header().Set("Content-Type", "application/json")
-			http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		if s.proxyToOwner(w, r, botIDFromPath(r)) {
 			return
 		}
 		next.ServeHTTP(w, r)
 	})
 }
 
-func (s *Server) usernameFromRequest(r *http.Request) string {
-	cookie, err := r.Cookie("session")
-	if err != nil {
-		return ""
+// proxyToOwner forwards r to the replica running botID, if that is another
+// replica. It reports whether the request has been handled.
+func (s *Server) proxyToOwner(w http.ResponseWriter, r *http.Request, botID string) bool {
+	if r.Header.Get(forwardedHeader) != "" {
+		return false
+	}
+	lease, remote := s.mgr.RemoteOwner(r.Context(), botID)
+	if !remote {
+		return false
+	}
+	target, err := url.Parse(lease.OwnerURL)
+	if lease.OwnerURL == "" || err != nil {
+		jsonError(w, fmt.Sprintf("bot is running on replica %q, which has no reachable INSTANCE_URL", lease.Owner),
+			http.StatusConflict)
+		return true
+	}
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.FlushInterval = -1 // stream event and log tails without buffering
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		jsonError(w, fmt.Sprintf("replica %q: %v", lease.Owner, err), http.StatusBadGateway)
 	}
-	username, _ := s.validSessionToken(cookie.Value)
-	return username
+	r.Header.Set(forwardedHeader, "1")
+	proxy.ServeHTTP(w, r)
+	return true
 }
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"bot-manager/internal/db"
+)
+
+// handleListOutbox lists a bot's queued messages.
+// GET /api/bots/{id}/outbox?status=dead|pending  (default: dead)
+func (s *Server) handleListOutbox(w http.ResponseWriter, r *http.Request) {
+	id := botIDFromPath(r)
+	status := db.OutboxStatus(r.URL.Query().Get("status"))
+	if status == "" {
+		status = db.OutboxDead
+	}
+	if status != db.OutboxDead && status != db.OutboxPending {
+		jsonError(w, "status must be dead or pending", http.StatusBadRequest)
+		return
+	}
+
+	items, err := s.database.ListOutbox(r.Context(), id, status)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if items == nil {
+		items = []db.OutboxItem{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
+
+// handleRetryOutbox moves a dead-lettered item back to the queue.
+// POST /api/bots/{id}/outbox/{itemID}/retry
+func (s *Server) handleRetryOutbox(w http.ResponseWriter, r *http.Request) {
+	id := botIDFromPath(r)
+	itemID, err := strconv.ParseInt(chi.URLParam(r, "itemID"), 10, 64)
+	if err != nil {
+		jsonError(w, "invalid item id", http.StatusBadRequest)
+		return
+	}
+	if err := s.database.RequeueOutboxItem(r.Context(), id, itemID); err != nil {
+		jsonError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Write([]byte(`{"ok":true}`))
+}
+
+// handleDeleteOutbox discards a dead-lettered item.
+// DELETE /api/bots/{id}/outbox/{itemID}
+func (s *Server) handleDeleteOutbox(w http.ResponseWriter, r *http.Request) {
+	id := botIDFromPath(r)
+	itemID, err := strconv.ParseInt(chi.URLParam(r, "itemID"), 10, 64)
+	if err != nil {
+		jsonError(w, "invalid item id", http.StatusBadRequest)
+		return
+	}
+	if err := s.database.DeleteOutboxItem(r.Context(), id, itemID); err != nil {
+		jsonError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"bot-manager/internal/db"
+)
+
+// botETag is the entity tag of a bot: its updated_at in microseconds, the
+// precision Postgres stores it with.
+func botETag(b db.Bot) string {
+	return `"` + strconv.FormatInt(b.UpdatedAt.UnixMicro(), 10) + `"`
+}
+
+// ifMatchVersion parses If-Match into the updated_at it refers to. A missing
+// header or "*" yields the zero time, meaning "don't check".
+func ifMatchVersion(r *http.Request) (time.Time, error) {
+	h := strings.TrimSpace(r.Header.Get("If-Match"))
+	if h == "" || h == "*" {
+		return time.Time{}, nil
+	}
+	h = strings.TrimPrefix(h, "W/")
+	micros, err := strconv.ParseInt(strings.Trim(h, `"`), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid If-Match %q", h)
+	}
+	return time.UnixMicro(micros), nil
+}
+
+// mergePatch applies an RFC 7386 JSON merge patch to target.
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+		} else {
+			t[k] = mergePatch(t[k], v)
+		}
+	}
+	return t
+}
+
+// handlePatchBot applies a JSON merge patch onto the stored bot, so fields the
+// client leaves out are kept. If-Match with the ETag from GET makes the update
+// fail with 412 if someone else changed the bot in the meantime.
+// PATCH /api/bots/{id}
+func (s *Server) handlePatchBot(w http.ResponseWriter, r *http.Request) {
+	id := botIDFromPath(r)
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		jsonError(w, "read body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	var patch interface{}
+	if err := json.Unmarshal(body, &patch); err != nil {
+		jsonError(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+	if _, ok := patch.(map[string]interface{}); !ok {
+		jsonError(w, "patch must be a JSON object", http.StatusBadRequest)
+		return
+	}
+
+	current, err := s.database.GetBot(r.Context(), id)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if !version.IsZero() && !version.Equal(current.UpdatedAt) {
+		jsonError(w, db.ErrStale.Error(), http.StatusPreconditionFailed)
+		return
+	}
+
+	// Patch the stored config without its token: an absent token keeps the
+	// stored one.
+	base := current
+	base.Token = ""
+	raw, _ := json.Marshal(base)
+	var doc interface{}
+	json.Unmarshal(raw, &doc) //nolint:errcheck
+	merged, _ := json.Marshal(mergePatch(doc, patch))
+
+	var bot db.Bot
+	if err := json.Unmarshal(merged, &bot); err != nil {
+		jsonError(w, "invalid patch: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	bot.ID = id
+	if !s.verifyBeforeSave(w, r, bot) {
+		return
+	}
+
+	if err := s.mgr.UpdateBotIfMatch(r.Context(), bot, current.UpdatedAt); err != nil {
+		managerError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	updated, _ := s.database.GetBot(r.Context(), id)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", botETag(updated))
+	json.NewEncoder(w).Encode(s.mgr.Redact(updated))
+}
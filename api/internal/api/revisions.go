@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"bot-manager/internal/db"
+)
+
+func (s *Server) handleListRevisions(w http.ResponseWriter, r *http.Request) {
+	revs, err := s.database.GetRevisions(r.Context(), botIDFromPath(r))
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if revs == nil {
+		revs = []db.Revision{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revs)
+}
+
+func (s *Server) handleGetRevision(w http.ResponseWriter, r *http.Request) {
+	rev, err := strconv.Atoi(chi.URLParam(r, "rev"))
+	if err != nil || rev < 1 {
+		jsonError(w, "invalid revision", http.StatusBadRequest)
+		return
+	}
+	revision, err := s.database.GetRevision(r.Context(), botIDFromPath(r), rev)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revision)
+}
+
+// handleDiffRevisions compares two revisions.
+// GET /api/bots/{id}/revisions/diff?from=3&to=5 — to defaults to the latest.
+func (s *Server) handleDiffRevisions(w http.ResponseWriter, r *http.Request) {
+	id := botIDFromPath(r)
+	from, err := strconv.Atoi(r.URL.Query().Get("from"))
+	if err != nil || from < 1 {
+		jsonError(w, "from must be a revision number", http.StatusBadRequest)
+		return
+	}
+	to := 0
+	if v := r.URL.Query().Get("to"); v != "" {
+		if to, err = strconv.Atoi(v); err != nil || to < 1 {
+			jsonError(w, "to must be a revision number", http.StatusBadRequest)
+			return
+		}
+	}
+
+	a, err := s.database.GetRevision(r.Context(), id, from)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	b, err := s.database.GetRevision(r.Context(), id, to)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	changes, err := db.DiffBots(a.Snapshot, b.Snapshot)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"from":    a.Rev,
+		"to":      b.Rev,
+		"changes": changes,
+	})
+}
+
+func (s *Server) handleRestoreRevision(w http.ResponseWriter, r *http.Request) {
+	rev, err := strconv.Atoi(chi.URLParam(r, "rev"))
+	if err != nil || rev < 1 {
+		jsonError(w, "invalid revision", http.StatusBadRequest)
+		return
+	}
+	bot, err := s.mgr.RestoreRevision(r.Context(), botIDFromPath(r), rev)
+	if err != nil {
+		managerError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.mgr.Redact(bot))
+}
@@ -4,6 +4,7 @@ import (
 	"io"
 	"io/fs"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -18,20 +19,20 @@ var zeroTime = time.Time{}
 
 type Server struct {
 	router        *chi.Mux
-	database      *db.DB
+	database      db.Store
 	minio         *storage.MinioStore
 	mgr           *manager.Manager
-	adminUsername string
-	passwordHash  string
 	sessionSecret []byte
 	distFS        fs.FS // embedded React frontend (nil = no static files served)
+
+	streamsDone  chan struct{} // closed on shutdown to end SSE streams
+	closeStreams sync.Once
 }
 
 func NewServer(
-	database *db.DB,
+	database db.Store,
 	minio *storage.MinioStore,
 	mgr *manager.Manager,
-	adminUsername, passwordHash string,
 	sessionSecret []byte,
 	distFS fs.FS,
 ) *Server {
@@ -39,10 +40,9 @@ func NewServer(
 		database:      database,
 		minio:         minio,
 		mgr:           mgr,
-		adminUsername: adminUsername,
-		passwordHash:  passwordHash,
 		sessionSecret: sessionSecret,
 		distFS:        distFS,
+		streamsDone:   make(chan struct{}),
 	}
 	s.router = s.buildRouter()
 	return s
@@ -50,6 +50,12 @@ func NewServer(
 
 func (s *Server) Handler() http.Handler { return s.router }
 
+// CloseStreams ends all open event streams. http.Server.Shutdown waits for
+// active requests, so register it with RegisterOnShutdown.
+func (s *Server) CloseStreams() {
+	s.closeStreams.Do(func() { close(s.streamsDone) })
+}
+
 func (s *Server) buildRouter() *chi.Mux {
 	r := chi.NewRouter()
 	r.Use(middleware.Logger)
@@ -61,30 +67,86 @@ func (s *Server) buildRouter() *chi.Mux {
 	r.Post("/api/auth/logout", s.handleLogout)
 	r.Get("/api/auth/me", s.handleMe)
 
-	// Bots (protected)
+	// Everything else requires a session; each route also requires a role
+	// (see db.Role): viewers read, operators also start and stop bots,
+	// editors also change them, owners also see tokens and manage users.
 	r.Group(func(r chi.Router) {
 		r.Use(s.authMiddleware)
 
+		r.Post("/api/auth/password", s.handleChangePassword)
+		r.Get("/api/auth/sessions", s.handleListSessions)
+		r.Delete("/api/auth/sessions", s.handleRevokeSessions)
+		r.Delete("/api/auth/sessions/{id}", s.handleRevokeSession)
+
 		r.Get("/api/bots", s.handleListBots)
-		r.Post("/api/bots", s.handleCreateBot)
 		r.Get("/api/bots/{id}", s.handleGetBot)
-		r.Put("/api/bots/{id}", s.handleUpdateBot)
-		r.Delete("/api/bots/{id}", s.handleDeleteBot)
+		r.Get("/api/bots/{id}/chats", s.handleListBotChats)
+		r.Get("/api/bots/{id}/revisions", s.handleListRevisions)
+		r.Get("/api/bots/{id}/revisions/diff", s.handleDiffRevisions)
+		r.Get("/api/bots/{id}/revisions/{rev}", s.handleGetRevision)
+		r.Get("/api/bots/{id}/assets", s.handleListAssets)
+		r.Get("/api/bots/{id}/outbox", s.handleListOutbox)
+		r.Get("/api/templates", s.handleListTemplates)
+		r.Get("/api/templates/{id}", s.handleGetTemplate)
+		r.Get("/api/events", s.handleEvents)
+
+		// Checks the role for each action: operator for start, stop and
+		// restart, editor for the rest.
+		r.Post("/api/bots/bulk", s.handleBulk)
+
+		// Lifecycle and logs are served by the replica that runs the bot.
+		r.Group(func(r chi.Router) {
+			r.Use(s.routeToOwner)
+			r.Get("/api/bots/{id}/logs", s.handleGetLogs)
+			r.Get("/api/bots/{id}/logs/stream", s.handleLogStream)
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(requireRole(db.RoleOperator))
+			r.Use(s.routeToOwner)
+			r.Post("/api/bots/{id}/start", s.handleStartBot)
+			r.Post("/api/bots/{id}/stop", s.handleStopBot)
+			r.Post("/api/bots/{id}/restart", s.handleRestartBot)
+		})
 
-		r.Post("/api/bots/{id}/start", s.handleStartBot)
-		r.Post("/api/bots/{id}/stop", s.handleStopBot)
-		r.Post("/api/bots/{id}/restart", s.handleRestartBot)
-		r.Get("/api/bots/{id}/logs", s.handleGetLogs)
+		r.Group(func(r chi.Router) {
+			r.Use(requireRole(db.RoleEditor))
+
+			r.Post("/api/bots", s.handleCreateBot)
+			r.Post("/api/bots/validate", s.handleValidateBot)
+			r.Post("/api/bots/resolve-chat", s.handleResolveChat)
+			r.Put("/api/bots/{id}", s.handleUpdateBot)
+			r.Patch("/api/bots/{id}", s.handlePatchBot)
+			r.Delete("/api/bots/{id}", s.handleDeleteBot)
+			r.Post("/api/bots/{id}/clone", s.handleCloneBot)
+			r.Post("/api/bots/{id}/template", s.handleSaveTemplate)
+			r.Post("/api/bots/{id}/revisions/{rev}/restore", s.handleRestoreRevision)
+
+			r.Delete("/api/templates/{id}", s.handleDeleteTemplate)
+			r.Post("/api/templates/{id}/bots", s.handleCreateFromTemplate)
+
+			r.Post("/api/bots/{id}/assets", s.handleUploadAsset)
+			r.Post("/api/bots/{id}/welcome", s.handleUploadWelcome)
+			r.Delete("/api/bots/{id}/assets/{key}", s.handleDeleteAsset)
+
+			r.Post("/api/bots/{id}/outbox/{itemID}/retry", s.handleRetryOutbox)
+			r.Delete("/api/bots/{id}/outbox/{itemID}", s.handleDeleteOutbox)
+
+			// Export / Import. Exporting tokens requires the owner role.
+			r.Get("/api/export", s.handleExport)
+			r.Post("/api/import", s.handleImport)
+			r.Post("/api/import/zip", s.handleImportZIP)
+		})
 
-		r.Get("/api/bots/{id}/assets", s.handleListAssets)
-		r.Post("/api/bots/{id}/assets", s.handleUploadAsset)
-		r.Post("/api/bots/{id}/welcome", s.handleUploadWelcome)
-		r.Delete("/api/bots/{id}/assets/{key}", s.handleDeleteAsset)
-
-		// Export / Import
-		r.Get("/api/export", s.handleExport)
-		r.Post("/api/import", s.handleImport)
-		r.Post("/api/import/zip", s.handleImportZIP)
+		r.Group(func(r chi.Router) {
+			r.Use(requireRole(db.RoleOwner))
+
+			r.Post("/api/bots/{id}/token/reveal", s.handleRevealToken)
+
+			r.Get("/api/users", s.handleListUsers)
+			r.Post("/api/users", s.handleCreateUser)
+			r.Patch("/api/users/{username}", s.handleUpdateUser)
+			r.Delete("/api/users/{username}", s.handleDeleteUser)
+		})
 	})
 
 	// Serve embedded React SPA with index.html fallback
@@ -129,8 +191,9 @@ func corsMiddleware(next http.Handler) http.Handler {
 		if origin != "" {
 			w.Header().Set("Access-Control-Allow-Origin", origin)
 			w.Header().Set("Access-Control-Allow-Credentials", "true")
-			w.Header().Set("Access-Control-Allow-Methods", "GET,POST,PUT,DELETE,OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type,Authorization")
+			w.Header().Set("Access-Control-Allow-Methods", "GET,POST,PUT,PATCH,DELETE,OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type,Authorization,If-Match")
+			w.Header().Set("Access-Control-Expose-Headers", "ETag")
 		}
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
@@ -0,0 +1,197 @@
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"bot-manager/internal/db"
+)
+
+// Sessions live in the database, so they can be listed and revoked and are
+// shared by all replicas. A session expires after sessionIdleTTL without
+// requests, and in any case sessionMaxAge after login.
+const (
+	sessionCookie        = "session"
+	sessionIdleTTL       = 24 * time.Hour
+	sessionMaxAge        = 30 * 24 * time.Hour
+	sessionTouchInterval = time.Minute // how often last_seen_at is written
+)
+
+// hashToken is what the sessions table stores instead of the cookie value.
+// It is keyed with the session secret, so rotating SESSION_SECRET still
+// logs everybody out.
+func (s *Server) hashToken(token string) string {
+	mac := hmac.New(sha256.New, s.sessionSecret)
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// clientIP is the address the request came from, without the port.
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// startSession creates a session for username and sets its cookie.
+func (s *Server) startSession(w http.ResponseWriter, r *http.Request, username string) error {
+	token, err := randomString(32)
+	if err != nil {
+		return err
+	}
+	id, err := randomString(12)
+	if err != nil {
+		return err
+	}
+	// Expired sessions are useless; a login is a good time to drop them.
+	if err := s.database.DeleteExpiredSessions(r.Context()); err != nil {
+		log.Printf("Ошибка удаления истёкших сессий: %v", err)
+	}
+	err = s.database.CreateSession(r.Context(), db.Session{
+		ID:        id,
+		TokenHash: s.hashToken(token),
+		Username:  username,
+		IP:        clientIP(r),
+		UserAgent: r.UserAgent(),
+		ExpiresAt: time.Now().Add(sessionIdleTTL),
+	})
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+		MaxAge:   int(sessionMaxAge / time.Second),
+		Path:     "/",
+	})
+	return nil
+}
+
+func clearSessionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:   sessionCookie,
+		Value:  "",
+		MaxAge: -1,
+		Path:   "/",
+	})
+}
+
+// userFromRequest returns the user and session of the session cookie, if the
+// session is still valid and the account still exists. Using a session moves
+// its expiry forward.
+func (s *Server) userFromRequest(r *http.Request) (db.User, db.Session, bool) {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil || cookie.Value == "" {
+		return db.User{}, db.Session{}, false
+	}
+	sess, err := s.database.GetSessionByToken(r.Context(), s.hashToken(cookie.Value))
+	if err != nil {
+		return db.User{}, db.Session{}, false
+	}
+	u, err := s.database.GetUser(r.Context(), sess.Username)
+	if err != nil {
+		return db.User{}, db.Session{}, false
+	}
+	if time.Since(sess.LastSeenAt) > sessionTouchInterval {
+		expires := time.Now().Add(sessionIdleTTL)
+		if limit := sess.CreatedAt.Add(sessionMaxAge); expires.After(limit) {
+			expires = limit
+		}
+		if err := s.database.TouchSession(r.Context(), sess.ID, expires); err != nil {
+			log.Printf("Ошибка обновления сессии %s: %v", sess.ID, err)
+		}
+	}
+	return u, sess, true
+}
+
+type sessionKey struct{}
+
+// sessionFrom returns the session authenticated by authMiddleware.
+func sessionFrom(r *http.Request) db.Session {
+	sess, _ := r.Context().Value(sessionKey{}).(db.Session)
+	return sess
+}
+
+func withSession(ctx context.Context, sess db.Session) context.Context {
+	return context.WithValue(ctx, sessionKey{}, sess)
+}
+
+// revokeSessions ends the sessions of username except keep ("" ends all).
+// Failures are logged: the caller has already made the change that calls
+// for the revocation.
+func (s *Server) revokeSessions(ctx context.Context, username, keep string) {
+	if _, err := s.database.DeleteSessions(ctx, username, keep); err != nil {
+		log.Printf("Ошибка отзыва сессий пользователя %s: %v", username, err)
+	}
+}
+
+// handleListSessions lists the sessions of the logged-in user.
+// GET /api/auth/sessions
+func (s *Server) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := s.database.GetSessions(r.Context(), userFrom(r).Username)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	type session struct {
+		db.Session
+		Current bool `json:"current"`
+	}
+	current := sessionFrom(r).ID
+	resp := make([]session, 0, len(sessions))
+	for _, sess := range sessions {
+		resp = append(resp, session{Session: sess, Current: sess.ID == current})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleRevokeSession ends one session of the logged-in user. Revoking the
+// current session is the same as logging out.
+// DELETE /api/auth/sessions/{id}
+func (s *Server) handleRevokeSession(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if err := s.database.DeleteSession(r.Context(), userFrom(r).Username, id); err != nil {
+		jsonError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if id == sessionFrom(r).ID {
+		clearSessionCookie(w)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleRevokeSessions ends all sessions of the logged-in user but the
+// current one.
+// DELETE /api/auth/sessions
+func (s *Server) handleRevokeSessions(w http.ResponseWriter, r *http.Request) {
+	n, err := s.database.DeleteSessions(r.Context(), userFrom(r).Username, sessionFrom(r).ID)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"revoked": n})
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"bot-manager/internal/db"
+	"bot-manager/internal/manager"
+	"bot-manager/internal/validation"
+)
+
+func (s *Server) handleListTemplates(w http.ResponseWriter, r *http.Request) {
+	templates, err := s.database.GetTemplates(r.Context())
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if templates == nil {
+		templates = []db.Template{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(templates)
+}
+
+func (s *Server) handleGetTemplate(w http.ResponseWriter, r *http.Request) {
+	t, err := s.database.GetTemplate(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		managerError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(t)
+}
+
+func (s *Server) handleDeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	if err := s.mgr.DeleteTemplate(r.Context(), chi.URLParam(r, "id")); err != nil {
+		managerError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleSaveTemplate saves a bot as a template.
+// POST /api/bots/{id}/template  {"id": "...", "name": "...", "description": "..."}
+func (s *Server) handleSaveTemplate(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+	req.ID = strings.TrimSpace(req.ID)
+	if !validation.ValidID(req.ID) {
+		jsonError(w, "id may contain only letters, digits, '-' and '_' (up to 64 characters)", http.StatusBadRequest)
+		return
+	}
+
+	t, err := s.mgr.SaveTemplate(r.Context(), botIDFromPath(r), db.Template{
+		ID:          req.ID,
+		Name:        req.Name,
+		Description: req.Description,
+	})
+	if err != nil {
+		managerError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(t)
+}
+
+// handleCreateFromTemplate creates a disabled bot from a template.
+// POST /api/templates/{id}/bots  {"id": "...", "token": "...", "channel_id": ...}
+func (s *Server) handleCreateFromTemplate(w http.ResponseWriter, r *http.Request) {
+	var p manager.CloneParams
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		jsonError(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	bot, err := s.mgr.CreateFromTemplate(r.Context(), chi.URLParam(r, "id"), p)
+	if err != nil {
+		managerError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(s.mgr.Redact(bot))
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/crypto/bcrypt"
+
+	"bot-manager/internal/db"
+	"bot-manager/internal/validation"
+)
+
+// Account management is for owners only (see buildRouter). Every change is
+// written to the audit log.
+
+func (s *Server) handleListUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := s.database.GetUsers(r.Context())
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if users == nil {
+		users = []db.User{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(users)
+}
+
+// handleCreateUser adds an account.
+// POST /api/users {"username", "password", "role"}
+func (s *Server) handleCreateUser(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Username string  `json:"username"`
+		Password string  `json:"password"`
+		Role     db.Role `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+	u := db.User{Username: strings.TrimSpace(req.Username), Role: req.Role}
+	if err := validation.User(u, req.Password); err != nil {
+		managerError(w, err, http.StatusBadRequest)
+		return
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	u.PasswordHash = string(hash)
+	if err := s.database.CreateUser(r.Context(), u); err != nil {
+		managerError(w, err, http.StatusInternalServerError)
+		return
+	}
+	s.database.RecordAudit(r.Context(), db.AuditUserCreate, u.Username, "role="+string(u.Role)) //nolint:errcheck
+
+	created, _ := s.database.GetUser(r.Context(), u.Username)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// handleUpdateUser changes the role and/or password of an account. Omitted
+// fields are kept; the last owner cannot be demoted. A new password revokes
+// all sessions of the account.
+// PATCH /api/users/{username} {"role", "password"}
+func (s *Server) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Password string  `json:"password"`
+		Role     db.Role `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+	u, err := s.database.GetUser(r.Context(), chi.URLParam(r, "username"))
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	var changes []string
+	if req.Role != "" && req.Role != u.Role {
+		u.Role = req.Role
+		changes = append(changes, "role="+string(req.Role))
+	}
+	if err := validation.User(u, req.Password); err != nil {
+		managerError(w, err, http.StatusBadRequest)
+		return
+	}
+	if req.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		u.PasswordHash = string(hash)
+		changes = append(changes, "password")
+	}
+
+	if len(changes) > 0 {
+		if err := s.database.UpdateUser(r.Context(), u); err != nil {
+			managerError(w, err, http.StatusInternalServerError)
+			return
+		}
+		if req.Password != "" {
+			s.revokeSessions(r.Context(), u.Username, "")
+		}
+		s.database.RecordAudit(r.Context(), db.AuditUserUpdate, u.Username, strings.Join(changes, ", ")) //nolint:errcheck
+	}
+
+	updated, _ := s.database.GetUser(r.Context(), u.Username)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// handleDeleteUser removes an account; the last owner cannot be removed.
+// DELETE /api/users/{username}
+func (s *Server) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
+	username := chi.URLParam(r, "username")
+	if _, err := s.database.GetUser(r.Context(), username); err != nil {
+		jsonError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err := s.database.DeleteUser(r.Context(), username); err != nil {
+		managerError(w, err, http.StatusInternalServerError)
+		return
+	}
+	s.database.RecordAudit(r.Context(), db.AuditUserDelete, username, "") //nolint:errcheck
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"bot-manager/internal/db"
+)
+
+// handleValidateBot checks a token and channel against Telegram without
+// saving anything. The body is a bot config; for an existing bot ("id") the
+// stored token and channel are used when omitted.
+// POST /api/bots/validate
+func (s *Server) handleValidateBot(w http.ResponseWriter, r *http.Request) {
+	var bot db.Bot
+	if err := json.NewDecoder(r.Body).Decode(&bot); err != nil {
+		jsonError(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.mgr.Verify(r.Context(), bot))
+}
+
+// verifyBeforeSave runs the Telegram check when the request asks for it with
+// ?verify=1. It reports whether saving may go ahead; if not, the response has
+// been written.
+func (s *Server) verifyBeforeSave(w http.ResponseWriter, r *http.Request, bot db.Bot) bool {
+	if r.URL.Query().Get("verify") != "1" {
+		return true
+	}
+	res := s.mgr.Verify(r.Context(), bot)
+	if res.OK {
+		return true
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":        "telegram verification failed",
+		"verification": res,
+	})
+	return false
+}
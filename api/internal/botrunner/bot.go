@@ -7,76 +7,200 @@ package botrunner
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"strings"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 
 	"bot-manager/internal/db"
-	"bot-manager/internal/storage"
 )
 
-// sendMsg converts Markdown to Telegram MarkdownV2 and sends the message.
-// On parse error, retries as plain text using the original (normalised) content.
-func sendMsg(bot *tgbotapi.BotAPI, logger *log.Logger, msg tgbotapi.MessageConfig) {
-	original := strings.ReplaceAll(msg.Text, `\n`, "\n")
-	msg.Text = mdToTelegramV2(msg.Text)
-	msg.ParseMode = tgbotapi.ModeMarkdownV2
-	if _, err := bot.Send(msg); err != nil {
-		if strings.Contains(err.Error(), "can't parse entities") {
-			logger.Printf("MarkdownV2 parse error (отправляю как plain text): %v", err)
-			msg.ParseMode = ""
-			msg.Text = original
-			if _, err2 := bot.Send(msg); err2 != nil {
-				logger.Printf("send message: %v", err2)
-			}
-		} else {
-			logger.Printf("send message: %v", err)
-		}
+// pollRetryDelay is how long the polling loop waits after a failed getUpdates.
+const pollRetryDelay = 3 * time.Second
+
+// ConflictError is returned when Telegram answers getUpdates with 409
+// Conflict: another process is polling the same token, or a webhook is set.
+type ConflictError struct {
+	Description string
+}
+
+func (e *ConflictError) Error() string {
+	return "token conflict: another process is polling this bot token or a webhook is set (Telegram: " +
+		e.Description + ")"
+}
+
+// ctxClient binds every Telegram request to the runner context so a pending
+// long poll is aborted immediately when the bot is stopped.
+type ctxClient struct {
+	ctx    context.Context
+	client *http.Client
+}
+
+func (c *ctxClient) Do(req *http.Request) (*http.Response, error) {
+	if !strings.HasSuffix(req.URL.Path, "/getUpdates") {
+		return c.client.Do(req.WithContext(c.ctx))
 	}
+	// The deadline also covers reading the body, so it ends with the body.
+	ctx, cancel := context.WithTimeout(c.ctx, pollDeadline)
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
 }
 
-func runBot(ctx context.Context, cfg db.Bot, store *storage.MinioStore, logger *log.Logger) error {
-	bot, err := tgbotapi.NewBotAPI(cfg.Token)
+// cancelBody releases a request context when the response body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+func runBot(
+	ctx context.Context,
+	cfg db.Bot,
+	conn Connection,
+	database Database,
+	store Objects,
+	logger *log.Logger,
+	emit func(Event),
+	st *runStats,
+) error {
+	bot, err := newBotAPI(ctx, cfg.Token, conn)
 	if err != nil {
 		return fmt.Errorf("auth: %w", err)
 	}
 	logger.Printf("Авторизован под @%s", bot.Self.UserName)
+	st.authorized(bot.Self.UserName)
+	st.polled()
 
+	out := newOutbox(bot, cfg.ID, database, store, logger, st)
+	dispatchCtx, stopDispatch := context.WithCancel(ctx)
+	dispatchDone := make(chan struct{})
+	go func() {
+		defer close(dispatchDone)
+		out.run(dispatchCtx)
+	}()
+	defer func() {
+		stopDispatch()
+		<-dispatchDone
+	}()
+
+	// Resume after the last update we fully handled. Telegram confirms an
+	// update only when a later getUpdates call passes a higher offset, so
+	// polling synchronously below means nothing is confirmed before it has
+	// been processed and persisted.
+	lastID, ok, err := database.GetUpdateOffset(ctx, cfg.ID)
+	if err != nil {
+		return fmt.Errorf("load update offset: %w", err)
+	}
 	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
-	updates := bot.GetUpdatesChan(u)
+	if ok {
+		u.Offset = lastID + 1
+		logger.Printf("Продолжаю с update_id %d", u.Offset)
+	}
+	u.Timeout = int(pollTimeout / time.Second)
+	// The offset is only valid for this token's Telegram bot; the store
+	// drops it when the token changes to another one.
+	tokenBotID, _, _ := strings.Cut(cfg.Token, ":")
+
+	staleAfter := time.Duration(cfg.StaleUpdateMinutes) * time.Minute
 
 	for {
-		select {
-		case <-ctx.Done():
-			bot.StopReceivingUpdates()
+		updates, err := bot.GetUpdates(u)
+		if ctx.Err() != nil {
 			return nil
-		case update, ok := <-updates:
-			if !ok {
-				return fmt.Errorf("updates channel closed")
+		}
+		st.polled()
+		var tgErr *tgbotapi.Error
+		if errors.As(err, &tgErr) && tgErr.Code == http.StatusConflict {
+			return &ConflictError{Description: tgErr.Message}
+		}
+		if err != nil {
+			st.failed()
+			logger.Printf("getUpdates: %v — повтор через %s", err, pollRetryDelay)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(pollRetryDelay):
+			}
+			continue
+		}
+
+		for _, update := range updates {
+			if update.UpdateID < u.Offset {
+				continue
+			}
+			st.update(update.CallbackQuery != nil)
+			if staleAfter > 0 && isStale(update, staleAfter) {
+				logger.Printf("Пропускаю устаревший update %d", update.UpdateID)
+			} else {
+				st.handling(true)
+				handleBotUpdate(ctx, bot, cfg, database, store, out, logger, emit, update)
+				st.handling(false)
+			}
+			u.Offset = update.UpdateID + 1
+			if err := database.SetUpdateOffset(ctx, cfg.ID, tokenBotID, update.UpdateID); err != nil {
+				logger.Printf("save update offset: %v", err)
 			}
-			handleBotUpdate(ctx, bot, cfg, store, logger, update)
 		}
 	}
 }
 
+// isStale reports whether the update carries a timestamp older than maxAge.
+// Updates without a timestamp (e.g. callback queries) are never stale.
+func isStale(update tgbotapi.Update, maxAge time.Duration) bool {
+	var date int
+	switch {
+	case update.Message != nil:
+		date = update.Message.Date
+	case update.EditedMessage != nil:
+		date = update.EditedMessage.Date
+	case update.MyChatMember != nil:
+		date = update.MyChatMember.Date
+	case update.ChatMember != nil:
+		date = update.ChatMember.Date
+	default:
+		return false
+	}
+	return time.Since(time.Unix(int64(date), 0)) > maxAge
+}
+
 func handleBotUpdate(
 	ctx context.Context,
 	bot *tgbotapi.BotAPI,
 	cfg db.Bot,
-	store *storage.MinioStore,
+	database Database,
+	store Objects,
+	out *outbox,
 	logger *log.Logger,
+	emit func(Event),
 	update tgbotapi.Update,
 ) {
+	if recordChatUpdate(ctx, database, cfg.ID, logger, update) {
+		return
+	}
+
 	// Ignore group/supergroup messages.
 	if update.Message != nil && update.Message.Chat.IsGroup() {
 		return
 	}
 
 	if update.Message != nil && update.Message.Command() == "start" {
-		sendWelcome(ctx, bot, cfg, store, logger, update.Message.Chat.ID)
+		emit(Event{Type: EventUser, Action: ActionStart, ChatID: update.Message.Chat.ID, UserID: fromID(update.Message.From)})
+		sendWelcome(ctx, cfg, out, update.Message.Chat.ID)
 		return
 	}
 
@@ -99,83 +223,56 @@ func handleBotUpdate(
 		}
 
 		if member.Status == "left" || member.Status == "kicked" {
-			sendNotSub(bot, cfg, logger, chatID)
+			emit(Event{Type: EventUser, Action: ActionNotSubscribed, ChatID: chatID, UserID: userID})
+			sendNotSub(ctx, cfg, out, chatID)
 			return
 		}
 
-		sendSuccess(ctx, bot, cfg, store, logger, chatID)
+		emit(Event{Type: EventUser, Action: ActionSubscribed, ChatID: chatID, UserID: userID})
+		sendSuccess(ctx, cfg, store, out, logger, chatID)
 	}
 }
 
-// sendWelcome sends the welcome message with an optional image.
-// Falls back to a text message if the image cannot be fetched/sent.
-func sendWelcome(
-	ctx context.Context,
-	bot *tgbotapi.BotAPI,
-	cfg db.Bot,
-	store *storage.MinioStore,
-	logger *log.Logger,
-	chatID int64,
-) {
-	kb := tgbotapi.NewInlineKeyboardMarkup(
+func fromID(u *tgbotapi.User) int64 {
+	if u == nil {
+		return 0
+	}
+	return u.ID
+}
+
+func subscribeKeyboard(cfg db.Bot) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData(cfg.ButtonText, "check_subscription"),
 		),
 	)
+}
 
+// sendWelcome queues the welcome message with an optional image.
+// The dispatcher falls back to a text message if the image cannot be sent.
+func sendWelcome(ctx context.Context, cfg db.Bot, out *outbox, chatID int64) {
+	item := db.OutboxItem{
+		ChatID:      chatID,
+		Kind:        db.OutboxMessage,
+		Text:        cfg.WelcomeMsg,
+		ReplyMarkup: marshalMarkup(subscribeKeyboard(cfg)),
+	}
 	if cfg.WelcomeImgKey != "" {
-		rc, _, err := store.GetObject(ctx, cfg.WelcomeImgKey)
-		if err == nil {
-			photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileReader{
-				Name:   "welcome.jpg",
-				Reader: rc,
-			})
-			photo.Caption = mdToTelegramV2(cfg.WelcomeMsg)
-			photo.ParseMode = tgbotapi.ModeMarkdownV2
-			photo.ReplyMarkup = kb
-			_, sendErr := bot.Send(photo)
-			rc.Close()
-			if sendErr == nil {
-				return
-			}
-			// Parse error — retry photo without parse mode.
-			if strings.Contains(sendErr.Error(), "can't parse entities") {
-				rc2, _, err2 := store.GetObject(ctx, cfg.WelcomeImgKey)
-				if err2 == nil {
-					photo2 := tgbotapi.NewPhoto(chatID, tgbotapi.FileReader{
-						Name:   "welcome.jpg",
-						Reader: rc2,
-					})
-					photo2.Caption = cfg.WelcomeMsg
-					photo2.ReplyMarkup = kb
-					_, sendErr2 := bot.Send(photo2)
-					rc2.Close()
-					if sendErr2 == nil {
-						return
-					}
-					logger.Printf("send photo (plain): %v — fallback to text", sendErr2)
-				}
-			} else {
-				logger.Printf("send photo: %v — fallback to text", sendErr)
-			}
-		}
+		item.Kind = db.OutboxPhoto
+		item.FileKey = cfg.WelcomeImgKey
+		item.FileName = "welcome.jpg"
 	}
-
-	msg := tgbotapi.NewMessage(chatID, cfg.WelcomeMsg)
-	msg.ReplyMarkup = kb
-	sendMsg(bot, logger, msg)
+	out.enqueue(ctx, item)
 }
 
 // sendNotSub informs the user they need to subscribe first.
-func sendNotSub(bot *tgbotapi.BotAPI, cfg db.Bot, logger *log.Logger, chatID int64) {
-	kb := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(cfg.ButtonText, "check_subscription"),
-		),
-	)
-	msg := tgbotapi.NewMessage(chatID, cfg.NotSubMsg)
-	msg.ReplyMarkup = kb
-	sendMsg(bot, logger, msg)
+func sendNotSub(ctx context.Context, cfg db.Bot, out *outbox, chatID int64) {
+	out.enqueue(ctx, db.OutboxItem{
+		ChatID:      chatID,
+		Kind:        db.OutboxMessage,
+		Text:        cfg.NotSubMsg,
+		ReplyMarkup: marshalMarkup(subscribeKeyboard(cfg)),
+	})
 }
 
 // sendSuccess delivers content to a verified subscriber.
@@ -183,9 +280,9 @@ func sendNotSub(bot *tgbotapi.BotAPI, cfg db.Bot, logger *log.Logger, chatID int
 // Otherwise, success_msg (which may contain Markdown links) is sent.
 func sendSuccess(
 	ctx context.Context,
-	bot *tgbotapi.BotAPI,
 	cfg db.Bot,
-	store *storage.MinioStore,
+	store Objects,
+	out *outbox,
 	logger *log.Logger,
 	chatID int64,
 ) {
@@ -196,36 +293,24 @@ func sendSuccess(
 		objects = nil
 	}
 
-	// If there are no files, fall back to success_msg (link-mode behaviour).
-	if len(objects) == 0 {
-		if cfg.SuccessMsg != "" {
-			sendMsg(bot, logger, tgbotapi.NewMessage(chatID, cfg.SuccessMsg))
-		}
-		return
-	}
-
-	// Has files — send optional success_msg first, then each document.
+	// success_msg goes first; with no files it is the whole payload
+	// (link-mode behaviour).
+	var items []db.OutboxItem
 	if cfg.SuccessMsg != "" {
-		sendMsg(bot, logger, tgbotapi.NewMessage(chatID, cfg.SuccessMsg))
+		items = append(items, db.OutboxItem{ChatID: chatID, Kind: db.OutboxMessage, Text: cfg.SuccessMsg})
 	}
 
 	for _, obj := range objects {
-		rc, _, err := store.GetObject(ctx, obj.Key)
-		if err != nil {
-			logger.Printf("get object %s: %v", obj.Key, err)
-			continue
-		}
-
 		parts := strings.Split(obj.Key, "/")
-		filename := parts[len(parts)-1]
-
-		doc := tgbotapi.NewDocument(chatID, tgbotapi.FileReader{
-			Name:   filename,
-			Reader: rc,
+		items = append(items, db.OutboxItem{
+			ChatID:   chatID,
+			Kind:     db.OutboxDocument,
+			FileKey:  obj.Key,
+			FileName: parts[len(parts)-1],
 		})
-		if _, err := bot.Send(doc); err != nil {
-			logger.Printf("send document %s: %v", filename, err)
-		}
-		rc.Close()
+	}
+
+	if len(items) > 0 {
+		out.enqueue(ctx, items...)
 	}
 }
@@ -0,0 +1,402 @@
+package botrunner
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"bot-manager/internal/db"
+	"bot-manager/internal/tgtest"
+)
+
+const (
+	testToken   = "123456789:AAbbccddeeffgghhiijjkkllmmnnooppqqr"
+	testChannel = -1001234567890
+	testUser    = 4242
+	waitTimeout = 5 * time.Second
+)
+
+func testBot() db.Bot {
+	return db.Bot{
+		ID:         "gate",
+		Token:      testToken,
+		ChannelID:  testChannel,
+		WelcomeMsg: "Welcome",
+		ButtonText: "Check",
+		NotSubMsg:  "Subscribe first",
+		SuccessMsg: "Thanks",
+	}
+}
+
+// harness runs runBot against a fake Bot API server and in-memory storage.
+type harness struct {
+	tg      *tgtest.Server
+	db      *db.MemStore
+	objects memObjects
+	stats   *runStats
+
+	mu     sync.Mutex
+	events []Event
+}
+
+func (h *harness) emit(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, e)
+}
+
+func (h *harness) actions() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var out []string
+	for _, e := range h.events {
+		out = append(out, e.Action)
+	}
+	return out
+}
+
+func newHarness(t *testing.T) *harness {
+	t.Helper()
+	tg := tgtest.NewServer(testToken)
+	t.Cleanup(tg.Close)
+	h := &harness{tg: tg, db: db.NewMemStore(), objects: memObjects{}, stats: &runStats{}}
+	if err := h.db.UpsertBot(context.Background(), testBot()); err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func (h *harness) outbox(status db.OutboxStatus) []db.OutboxItem {
+	items, _ := h.db.ListOutbox(context.Background(), "gate", status)
+	return items
+}
+
+func (h *harness) chats() map[int64]db.BotChat {
+	chats, _ := h.db.GetBotChats(context.Background(), "gate")
+	out := map[int64]db.BotChat{}
+	for _, c := range chats {
+		out[c.ChatID] = c
+	}
+	return out
+}
+
+// start runs the bot until the test ends and returns a channel with its
+// result.
+func (h *harness) start(t *testing.T, cfg db.Bot) <-chan error {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		logger := log.New(io.Discard, "", 0)
+		done <- runBot(ctx, cfg, Connection{Endpoint: h.tg.URL}, h.db, h.objects, logger, h.emit, h.stats)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		select {
+		case <-stopped:
+		case <-time.After(waitTimeout):
+			t.Error("runBot did not stop")
+		}
+	})
+	return done
+}
+
+func (h *harness) wait(t *testing.T, n int, methods ...string) []tgtest.Call {
+	t.Helper()
+	calls, err := h.tg.WaitCalls(waitTimeout, n, methods...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return calls
+}
+
+// eventually polls cond until it holds or the wait times out.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(waitTimeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStartCheckDeliver(t *testing.T) {
+	h := newHarness(t)
+	h.objects["gate/docs/guide.pdf"] = []byte("%PDF guide")
+	h.start(t, testBot())
+
+	h.tg.SendText(testUser, "/start")
+	welcome := h.wait(t, 1, "sendMessage")[0]
+	if welcome.ChatID() != testUser || welcome.Params.Get("text") != "Welcome" {
+		t.Fatalf("welcome = chat %d %q", welcome.ChatID(), welcome.Params.Get("text"))
+	}
+	if welcome.Params.Get("parse_mode") != tgbotapi.ModeMarkdownV2 {
+		t.Errorf("welcome parse_mode = %q", welcome.Params.Get("parse_mode"))
+	}
+	if !strings.Contains(welcome.Params.Get("reply_markup"), "check_subscription") {
+		t.Errorf("welcome has no check button: %s", welcome.Params.Get("reply_markup"))
+	}
+
+	// Not subscribed yet.
+	h.tg.PressButton(testUser, "check_subscription")
+	notSub := h.wait(t, 2, "sendMessage")[1]
+	if notSub.Params.Get("text") != "Subscribe first" {
+		t.Fatalf("not-subscribed reply = %q", notSub.Params.Get("text"))
+	}
+	if len(h.tg.Calls("answerCallbackQuery")) != 1 {
+		t.Error("callback query was not answered")
+	}
+
+	h.tg.SetMember(testChannel, testUser, "member")
+	h.tg.PressButton(testUser, "check_subscription")
+	success := h.wait(t, 3, "sendMessage")[2]
+	if success.Params.Get("text") != "Thanks" {
+		t.Fatalf("success reply = %q", success.Params.Get("text"))
+	}
+	doc := h.wait(t, 1, "sendDocument")[0]
+	f, ok := doc.Files["document"]
+	if !ok || f.Name != "guide.pdf" || string(f.Data) != "%PDF guide" {
+		t.Fatalf("document = %+v", doc.Files)
+	}
+	if doc.ChatID() != testUser {
+		t.Errorf("document sent to %d", doc.ChatID())
+	}
+
+	// The membership checks went to the configured channel.
+	for _, c := range h.tg.Calls("getChatMember") {
+		if c.ChatID() != testChannel {
+			t.Errorf("getChatMember chat_id = %d", c.ChatID())
+		}
+	}
+
+	want := []string{ActionStart, ActionNotSubscribed, ActionSubscribed}
+	if got := h.actions(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+	eventually(t, "outbox to drain", func() bool { return len(h.outbox(db.OutboxPending)) == 0 })
+	eventually(t, "offset to be saved", func() bool {
+		id, ok, _ := h.db.GetUpdateOffset(context.Background(), "gate")
+		return ok && id == 3
+	})
+}
+
+func TestStats(t *testing.T) {
+	h := newHarness(t)
+	h.start(t, testBot())
+	eventually(t, "authorisation", func() bool { return h.stats.snapshot().Username == "test_bot" })
+
+	h.tg.SendText(testUser, "/start")
+	h.tg.PressButton(testUser, "check_subscription")
+	h.wait(t, 2, "sendMessage")
+	eventually(t, "deliveries to be counted", func() bool { return h.stats.snapshot().Deliveries == 2 })
+
+	st := h.stats.snapshot()
+	if st.Updates != 2 || st.Callbacks != 1 || st.Errors != 0 {
+		t.Errorf("stats = %+v, want 2 updates, 1 callback, no errors", st)
+	}
+	if time.Since(st.LastUpdateAt) > waitTimeout {
+		t.Errorf("LastUpdateAt = %v", st.LastUpdateAt)
+	}
+
+	// A failed send attempt counts as an error.
+	h.tg.Fail("sendMessage", 502, "Bad Gateway", 1)
+	h.tg.SendText(testUser, "/start")
+	eventually(t, "the failed attempt to be counted", func() bool { return h.stats.snapshot().Errors == 1 })
+	if st := h.stats.snapshot(); st.Updates != 3 || st.Deliveries != 2 {
+		t.Errorf("stats after a failed send = %+v", st)
+	}
+}
+
+func TestWelcomePhoto(t *testing.T) {
+	h := newHarness(t)
+	h.objects["gate/welcome/banner.jpg"] = []byte("jpeg")
+	cfg := testBot()
+	cfg.WelcomeImgKey = "gate/welcome/banner.jpg"
+	h.start(t, cfg)
+
+	h.tg.SendText(testUser, "/start")
+	photo := h.wait(t, 1, "sendPhoto")[0]
+	if photo.Params.Get("caption") != "Welcome" {
+		t.Errorf("caption = %q", photo.Params.Get("caption"))
+	}
+	if f := photo.Files["photo"]; string(f.Data) != "jpeg" {
+		t.Errorf("photo = %+v", photo.Files)
+	}
+	if len(h.tg.Calls("sendMessage")) != 0 {
+		t.Error("welcome was also sent as text")
+	}
+}
+
+func TestWelcomePhotoMissingFallsBackToText(t *testing.T) {
+	h := newHarness(t)
+	cfg := testBot()
+	cfg.WelcomeImgKey = "gate/welcome/gone.jpg"
+	h.start(t, cfg)
+
+	h.tg.SendText(testUser, "/start")
+	msg := h.wait(t, 1, "sendMessage")[0]
+	if msg.Params.Get("text") != "Welcome" {
+		t.Errorf("text = %q", msg.Params.Get("text"))
+	}
+}
+
+func TestLinkBotSendsSuccessMessageOnly(t *testing.T) {
+	h := newHarness(t)
+	cfg := testBot()
+	cfg.SuccessMsg = "Join [here](https://t.me/+secret)"
+	h.tg.SetMember(testChannel, testUser, "administrator")
+	h.start(t, cfg)
+
+	h.tg.PressButton(testUser, "check_subscription")
+	msg := h.wait(t, 1, "sendMessage")[0]
+	if want := mdToTelegramV2(cfg.SuccessMsg); msg.Params.Get("text") != want {
+		t.Errorf("text = %q, want %q", msg.Params.Get("text"), want)
+	}
+	eventually(t, "outbox to drain", func() bool { return len(h.outbox(db.OutboxPending)) == 0 })
+	if len(h.tg.Calls("sendDocument")) != 0 {
+		t.Error("link bot sent a document")
+	}
+}
+
+func TestMarkdownParseErrorFallsBackToPlainText(t *testing.T) {
+	h := newHarness(t)
+	h.tg.Fail("sendMessage", 400, "Bad Request: can't parse entities: unexpected end", 1)
+	h.start(t, testBot())
+
+	h.tg.SendText(testUser, "/start")
+	calls := h.wait(t, 2, "sendMessage")
+	if calls[1].Params.Get("parse_mode") != "" || calls[1].Params.Get("text") != "Welcome" {
+		t.Errorf("retry = %v", calls[1].Params)
+	}
+}
+
+func TestPermanentSendErrorIsDeadLettered(t *testing.T) {
+	h := newHarness(t)
+	h.tg.Fail("sendMessage", 403, "Forbidden: bot was blocked by the user", 1)
+	h.start(t, testBot())
+
+	h.tg.SendText(testUser, "/start")
+	eventually(t, "dead letter", func() bool { return len(h.outbox(db.OutboxDead)) == 1 })
+	dead := h.outbox(db.OutboxDead)[0]
+	if dead.Attempts != 1 || !strings.Contains(dead.LastError, "blocked") {
+		t.Errorf("dead item = %+v", dead)
+	}
+}
+
+func TestResumesAfterSavedOffset(t *testing.T) {
+	h := newHarness(t)
+	h.db.SetUpdateOffset(context.Background(), "gate", "", 1)
+	h.tg.SendText(1, "/start") // update 1: handled before the restart
+	h.tg.SendText(2, "/start") // update 2
+	h.start(t, testBot())
+
+	msg := h.wait(t, 1, "sendMessage")[0]
+	if msg.ChatID() != 2 {
+		t.Fatalf("first reply went to chat %d, want 2", msg.ChatID())
+	}
+	eventually(t, "offset to be saved", func() bool {
+		id, _, _ := h.db.GetUpdateOffset(context.Background(), "gate")
+		return id == 2
+	})
+	if n := len(h.tg.Calls("sendMessage")); n != 1 {
+		t.Errorf("%d messages sent, want 1", n)
+	}
+}
+
+func TestStaleUpdatesAreSkipped(t *testing.T) {
+	h := newHarness(t)
+	cfg := testBot()
+	cfg.StaleUpdateMinutes = 5
+	old := &tgbotapi.Message{
+		MessageID: 1,
+		From:      &tgbotapi.User{ID: 1},
+		Date:      int(time.Now().Add(-time.Hour).Unix()),
+		Chat:      &tgbotapi.Chat{ID: 1, Type: "private"},
+		Text:      "/start",
+		Entities:  []tgbotapi.MessageEntity{{Type: "bot_command", Length: 6}},
+	}
+	h.tg.PushUpdate(tgbotapi.Update{Message: old})
+	h.tg.SendText(2, "/start")
+	h.start(t, cfg)
+
+	msg := h.wait(t, 1, "sendMessage")[0]
+	if msg.ChatID() != 2 {
+		t.Fatalf("reply went to chat %d, want 2", msg.ChatID())
+	}
+}
+
+func TestRecordsChats(t *testing.T) {
+	h := newHarness(t)
+	h.start(t, testBot())
+
+	channel := tgbotapi.Chat{ID: testChannel, Type: "channel", Title: "News", UserName: "news"}
+	h.tg.PushUpdate(tgbotapi.Update{MyChatMember: &tgbotapi.ChatMemberUpdated{
+		Chat:          channel,
+		From:          tgbotapi.User{ID: testUser},
+		Date:          int(time.Now().Unix()),
+		OldChatMember: tgbotapi.ChatMember{Status: "left"},
+		NewChatMember: tgbotapi.ChatMember{Status: "administrator"},
+	}})
+	other := tgbotapi.Chat{ID: -1009876543210, Type: "channel", Title: "Other"}
+	fwd := &tgbotapi.Message{
+		MessageID:       77,
+		From:            &tgbotapi.User{ID: testUser},
+		Date:            int(time.Now().Unix()),
+		Chat:            &tgbotapi.Chat{ID: testUser, Type: "private"},
+		Text:            "post",
+		ForwardFromChat: &other,
+	}
+	h.tg.PushUpdate(tgbotapi.Update{Message: fwd})
+
+	eventually(t, "chats to be recorded", func() bool { return len(h.chats()) == 2 })
+	chats := h.chats()
+	if c := chats[testChannel]; !c.IsAdmin() || c.Title != "News" || c.Username != "news" {
+		t.Errorf("channel = %+v", c)
+	}
+	if c := chats[other.ID]; c.ForwardMessageID != 77 || c.Status != "" {
+		t.Errorf("forwarded chat = %+v", c)
+	}
+}
+
+func TestConflictStopsRun(t *testing.T) {
+	h := newHarness(t)
+	h.tg.Fail("getUpdates", 409, "Conflict: terminated by other getUpdates request", 1)
+	done := h.start(t, testBot())
+
+	select {
+	case err := <-done:
+		var conflict *ConflictError
+		if !errors.As(err, &conflict) {
+			t.Fatalf("runBot = %v, want a ConflictError", err)
+		}
+	case <-time.After(waitTimeout):
+		t.Fatal("runBot kept running after a 409")
+	}
+}
+
+func TestRejectedToken(t *testing.T) {
+	h := newHarness(t)
+	cfg := testBot()
+	cfg.Token = "1:wrong"
+	done := h.start(t, cfg)
+
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "auth") {
+			t.Fatalf("runBot = %v, want an auth error", err)
+		}
+	case <-time.After(waitTimeout):
+		t.Fatal("runBot kept running with a rejected token")
+	}
+}
@@ -0,0 +1,133 @@
+package botrunner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"bot-manager/internal/db"
+)
+
+// ResolvedChat is what getChat returns for a channel reference.
+type ResolvedChat struct {
+	ID       int64  `json:"id"`
+	Title    string `json:"title"`
+	Type     string `json:"type"`
+	Username string `json:"username,omitempty"`
+}
+
+var chatUsernameRe = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{3,31}$`)
+
+// ErrInviteLink is returned for private invite links: Telegram does not let
+// bots look a chat up by its invite link.
+var ErrInviteLink = errors.New("private invite links cannot be resolved; forward a post from the channel to the bot instead")
+
+// ParseChatRef turns "@name", "name", "t.me/name", "https://t.me/name/123" or
+// a numeric chat ID into either a chat ID or an "@name" for getChat.
+func ParseChatRef(ref string) (chatID int64, username string, err error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return 0, "", errors.New("empty chat reference")
+	}
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		return id, "", nil
+	}
+
+	name := strings.TrimPrefix(ref, "@")
+	if strings.Contains(ref, "t.me/") || strings.Contains(ref, "telegram.me/") {
+		if !strings.Contains(ref, "://") {
+			ref = "https://" + ref
+		}
+		u, err := url.Parse(ref)
+		if err != nil {
+			return 0, "", fmt.Errorf("invalid link %q: %v", ref, err)
+		}
+		path := strings.Trim(u.Path, "/")
+		if strings.HasPrefix(path, "+") || strings.HasPrefix(path, "joinchat/") {
+			return 0, "", ErrInviteLink
+		}
+		name, _, _ = strings.Cut(path, "/")
+		if name == "s" || name == "c" {
+			// t.me/s/name is the web preview; t.me/c/<id> links need the chat ID.
+			parts := strings.Split(path, "/")
+			if len(parts) < 2 {
+				return 0, "", fmt.Errorf("invalid link %q", ref)
+			}
+			if name == "c" {
+				id, err := strconv.ParseInt(parts[1], 10, 64)
+				if err != nil {
+					return 0, "", fmt.Errorf("invalid link %q", ref)
+				}
+				return -1000000000000 - id, "", nil
+			}
+			name = parts[1]
+		}
+	}
+	if !chatUsernameRe.MatchString(name) {
+		return 0, "", fmt.Errorf("%q is not a chat username or link", ref)
+	}
+	return 0, "@" + name, nil
+}
+
+// ResolveChat looks a chat reference (see ParseChatRef) up with getChat
+// using the bot's token. Private channels are only found if the bot is a
+// member.
+func ResolveChat(ctx context.Context, token string, conn Connection, ref string) (ResolvedChat, error) {
+	chatID, username, err := ParseChatRef(ref)
+	if err != nil {
+		return ResolvedChat{}, err
+	}
+	bot, err := newBotAPI(ctx, token, conn)
+	if err != nil {
+		return ResolvedChat{}, fmt.Errorf("token rejected by Telegram: %v", err)
+	}
+	chat, err := bot.GetChat(tgbotapi.ChatInfoConfig{
+		ChatConfig: tgbotapi.ChatConfig{ChatID: chatID, SuperGroupUsername: username},
+	})
+	if err != nil {
+		return ResolvedChat{}, fmt.Errorf("getChat %s: %v", ref, err)
+	}
+	return ResolvedChat{ID: chat.ID, Title: chat.Title, Type: chat.Type, Username: chat.UserName}, nil
+}
+
+// recordChatUpdate stores chats the bot was added to or removed from and
+// channels whose posts were forwarded to it in private, so the UI can offer
+// them as channel IDs. It reports whether the update was consumed.
+func recordChatUpdate(ctx context.Context, database Database, botID string, logger *log.Logger, update tgbotapi.Update) bool {
+	if m := update.MyChatMember; m != nil {
+		c := botChat(botID, m.Chat)
+		c.Status = m.NewChatMember.Status
+		if err := database.UpsertBotChat(ctx, c); err != nil {
+			logger.Printf("save chat %d: %v", m.Chat.ID, err)
+		}
+		logger.Printf("Статус в чате %q (%d): %s → %s", m.Chat.Title, m.Chat.ID,
+			m.OldChatMember.Status, m.NewChatMember.Status)
+		return true
+	}
+
+	if m := update.Message; m != nil && m.Chat.IsPrivate() && m.ForwardFromChat != nil {
+		c := botChat(botID, *m.ForwardFromChat)
+		c.ForwardMessageID = m.MessageID
+		if err := database.UpsertBotChat(ctx, c); err != nil {
+			logger.Printf("save forwarded chat %d: %v", m.ForwardFromChat.ID, err)
+		}
+	}
+	return false
+}
+
+func botChat(botID string, chat tgbotapi.Chat) db.BotChat {
+	return db.BotChat{
+		BotID:    botID,
+		ChatID:   chat.ID,
+		Type:     chat.Type,
+		Title:    chat.Title,
+		Username: chat.UserName,
+	}
+}
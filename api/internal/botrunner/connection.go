@@ -0,0 +1,97 @@
+package botrunner
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"bot-manager/internal/db"
+)
+
+// Connection says how to reach the Telegram Bot API.
+type Connection struct {
+	// Endpoint is the base URL of the Bot API server, e.g.
+	// "http://telegram-bot-api:8081" for a self-hosted one; "" means
+	// api.telegram.org.
+	Endpoint string
+	// Proxy is an http://, https:// or socks5:// proxy URL, optionally with
+	// credentials; "" or "direct" connects directly.
+	Proxy string
+}
+
+// For returns the connection of bot b: its own settings where set, c's
+// otherwise.
+func (c Connection) For(b db.Bot) Connection {
+	if b.APIEndpoint != "" {
+		c.Endpoint = b.APIEndpoint
+	}
+	if b.Proxy != "" {
+		c.Proxy = b.Proxy
+	}
+	return c
+}
+
+// apiEndpoint is Endpoint in the "<base>/bot%s/%s" form tgbotapi expects.
+func (c Connection) apiEndpoint() string {
+	if c.Endpoint == "" {
+		return tgbotapi.APIEndpoint
+	}
+	return strings.TrimRight(c.Endpoint, "/") + "/bot%s/%s"
+}
+
+// pollTimeout is how long a getUpdates long poll waits for updates on the
+// Telegram side.
+const pollTimeout = 60 * time.Second
+
+// Timeouts of Bot API requests, so a hung connection fails instead of
+// blocking the runner where the watchdog cannot see it. A whole request has
+// no limit, since uploading a large document over a slow link (or to a local
+// Bot API server) may take any time; connecting, the TLS handshake and the
+// wait for the response once the request is sent are bounded instead, and a
+// getUpdates call gets a deadline. Variables for tests.
+var (
+	dialTimeout         = 30 * time.Second
+	tlsHandshakeTimeout = 10 * time.Second
+	// responseTimeout must leave a long poll time to return: Telegram
+	// answers getUpdates only when the poll ends.
+	responseTimeout = pollTimeout + 30*time.Second
+	pollDeadline    = pollTimeout + 30*time.Second
+)
+
+// httpClient returns a client that goes through c's proxy. Without one it
+// falls back to HTTP_PROXY/HTTPS_PROXY; "direct" ignores those too.
+func (c Connection) httpClient() (*http.Client, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = (&net.Dialer{Timeout: dialTimeout, KeepAlive: 30 * time.Second}).DialContext
+	t.TLSHandshakeTimeout = tlsHandshakeTimeout
+	t.ResponseHeaderTimeout = responseTimeout
+	switch c.Proxy {
+	case "":
+	case db.ProxyDirect:
+		t.Proxy = nil
+	default:
+		u, err := url.Parse(c.Proxy)
+		if err != nil {
+			// The parse error would repeat the URL and its password.
+			return nil, fmt.Errorf("invalid proxy URL")
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+	return &http.Client{Transport: t}, nil
+}
+
+// newBotAPI authorizes token (getMe) with every request bound to ctx.
+func newBotAPI(ctx context.Context, token string, conn Connection) (*tgbotapi.BotAPI, error) {
+	client, err := conn.httpClient()
+	if err != nil {
+		return nil, err
+	}
+	return tgbotapi.NewBotAPIWithClient(token, conn.apiEndpoint(),
+		&ctxClient{ctx: ctx, client: client})
+}
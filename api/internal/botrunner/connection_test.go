@@ -0,0 +1,184 @@
+package botrunner
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"bot-manager/internal/tgtest"
+)
+
+// setTimeouts overrides the request timeouts until the test ends.
+func setTimeouts(t *testing.T, response, poll time.Duration) {
+	oldResponse, oldPoll := responseTimeout, pollDeadline
+	responseTimeout, pollDeadline = response, poll
+	t.Cleanup(func() { responseTimeout, pollDeadline = oldResponse, oldPoll })
+}
+
+func TestResponseTimeout(t *testing.T) {
+	if responseTimeout <= pollTimeout || pollDeadline <= pollTimeout {
+		t.Fatalf("timeouts %s, %s do not leave a long poll of %s time to return", responseTimeout, pollDeadline, pollTimeout)
+	}
+
+	// A Bot API server that never answers.
+	hung := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-hung:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(hung)
+	setTimeouts(t, 100*time.Millisecond, time.Minute)
+
+	start := time.Now()
+	_, err := newBotAPI(context.Background(), testToken, Connection{Endpoint: srv.URL})
+	if err == nil {
+		t.Fatal("getMe against a hung server succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("getMe gave up after %s", elapsed)
+	}
+}
+
+func TestPollDeadline(t *testing.T) {
+	// getUpdates answers with headers, then the body never comes.
+	hung := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/getMe") {
+			io.WriteString(w, `{"ok":true,"result":{"id":123456789,"is_bot":true,"username":"test_bot"}}`)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		select {
+		case <-hung:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(hung)
+	setTimeouts(t, time.Minute, 100*time.Millisecond)
+
+	bot, err := newBotAPI(context.Background(), testToken, Connection{Endpoint: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if _, err := bot.GetUpdates(tgbotapi.NewUpdate(0)); err == nil {
+		t.Fatal("getUpdates with a stalled body succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("getUpdates gave up after %s", elapsed)
+	}
+}
+
+func TestSlowUploadIsNotCutOff(t *testing.T) {
+	// The server takes longer than every timeout to read the upload, which
+	// is larger than the socket buffers.
+	const delay = 300 * time.Millisecond
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/getMe") {
+			io.WriteString(w, `{"ok":true,"result":{"id":123456789,"is_bot":true,"username":"test_bot"}}`)
+			return
+		}
+		time.Sleep(delay)
+		io.Copy(io.Discard, r.Body)
+		io.WriteString(w, `{"ok":true,"result":{"message_id":1,"date":0,"chat":{"id":4242,"type":"private"}}}`)
+	}))
+	defer srv.Close()
+	setTimeouts(t, 100*time.Millisecond, 100*time.Millisecond)
+
+	bot, err := newBotAPI(context.Background(), testToken, Connection{Endpoint: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := tgbotapi.NewDocument(testUser, tgbotapi.FileBytes{Name: "big.bin", Bytes: make([]byte, 32<<20)})
+	start := time.Now()
+	if _, err := bot.Send(doc); err != nil {
+		t.Fatalf("slow upload: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < delay {
+		t.Errorf("upload took %s; the server did not hold it", elapsed)
+	}
+}
+
+func TestConnectionFor(t *testing.T) {
+	base := Connection{Endpoint: "http://local:8081", Proxy: "socks5://proxy:1080"}
+	if got := base.For(testBot()); got != base {
+		t.Errorf("For(bot without overrides) = %+v", got)
+	}
+	b := testBot()
+	b.APIEndpoint, b.Proxy = "https://api.example.com", "direct"
+	if got := base.For(b); got.Endpoint != b.APIEndpoint || got.Proxy != "direct" {
+		t.Errorf("For(bot with overrides) = %+v", got)
+	}
+	if (Connection{}).apiEndpoint() != "https://api.telegram.org/bot%s/%s" {
+		t.Errorf("default endpoint = %q", (Connection{}).apiEndpoint())
+	}
+	if got := (Connection{Endpoint: "http://local:8081/"}).apiEndpoint(); got != "http://local:8081/bot%s/%s" {
+		t.Errorf("endpoint = %q", got)
+	}
+	if _, err := (Connection{Proxy: "://bad"}).httpClient(); err == nil {
+		t.Error("invalid proxy accepted")
+	}
+}
+
+func TestProxy(t *testing.T) {
+	tg := tgtest.NewServer(testToken)
+	defer tg.Close()
+
+	// A forward proxy that records what it is asked for.
+	var mu sync.Mutex
+	var seen []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r.Header.Get("Proxy-Authorization")+" "+r.URL.String())
+		mu.Unlock()
+		r.RequestURI = ""
+		r.Header.Del("Proxy-Authorization")
+		resp, err := http.DefaultTransport.RoundTrip(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}))
+	defer proxy.Close()
+
+	conn := Connection{Endpoint: tg.URL, Proxy: "http://user:secret@" + strings.TrimPrefix(proxy.URL, "http://")}
+	bot, err := newBotAPI(context.Background(), testToken, conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bot.Self.UserName != "test_bot" {
+		t.Errorf("authorised as %q", bot.Self.UserName)
+	}
+	auth := "Basic " + base64.StdEncoding.EncodeToString([]byte("user:secret"))
+	mu.Lock()
+	defer mu.Unlock()
+	if len(seen) != 1 || seen[0] != auth+" "+tg.URL+"/bot"+testToken+"/getMe" {
+		t.Errorf("proxy saw %q", seen)
+	}
+
+	// "direct" bypasses HTTP_PROXY/HTTPS_PROXY; no setting keeps them.
+	direct, _ := Connection{Proxy: "direct"}.httpClient()
+	if direct.Transport.(*http.Transport).Proxy != nil {
+		t.Error("direct connection uses a proxy")
+	}
+	env, _ := Connection{}.httpClient()
+	if env.Transport.(*http.Transport).Proxy == nil {
+		t.Error("default connection ignores the proxy environment")
+	}
+}
@@ -0,0 +1,51 @@
+package botrunner
+
+import "time"
+
+type EventType string
+
+const (
+	EventStatus EventType = "status" // runner status transition
+	EventLog    EventType = "log"    // line written to the bot log
+	EventUser   EventType = "user"   // a Telegram user interacted with the bot
+)
+
+// User event actions.
+const (
+	ActionStart         = "start"          // /start received
+	ActionSubscribed    = "subscribed"     // subscription check passed, content queued
+	ActionNotSubscribed = "not_subscribed" // subscription check failed
+)
+
+// Event is published by a runner to Options.OnEvent. Only the fields relevant
+// to Type are set.
+type Event struct {
+	Type      EventType `json:"type"`
+	BotID     string    `json:"bot_id"`
+	Time      time.Time `json:"time"`
+	Status    BotStatus `json:"status,omitempty"`
+	StatusMsg string    `json:"status_msg,omitempty"`
+	Line      string    `json:"line,omitempty"`
+	Action    string    `json:"action,omitempty"`
+	ChatID    int64     `json:"chat_id,omitempty"`
+	UserID    int64     `json:"user_id,omitempty"`
+}
+
+// Options carries the settings a runner receives from its manager.
+type Options struct {
+	// OnEvent, if set, receives every event of the runner. It is called
+	// synchronously, sometimes with the runner lock held, so it must not block.
+	OnEvent func(Event)
+	// Connection is the default way to reach the Bot API; bots can
+	// override it in their config.
+	Connection Connection
+}
+
+func (r *BotRunner) emit(e Event) {
+	if r.opts.OnEvent == nil {
+		return
+	}
+	e.BotID = r.Cfg.ID
+	e.Time = time.Now()
+	r.opts.OnEvent(e)
+}
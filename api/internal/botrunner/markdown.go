@@ -16,6 +16,7 @@ package botrunner
 // All other MarkdownV2 special characters are escaped with backslash.
 
 import (
+	"fmt"
 	"regexp"
 	"strings"
 )
@@ -111,3 +112,34 @@ func mdToTelegramV2(input string) string {
 	b.WriteString(escPlain(input[last:]))
 	return b.String()
 }
+
+// CheckMarkdown reports constructs that mdToTelegramV2 passes through as-is
+// but Telegram rejects when parsing MarkdownV2, such as a backslash inside
+// code or a link without a URL. Such text would only be sent as plain text.
+func CheckMarkdown(input string) error {
+	input = strings.ReplaceAll(input, `\n`, "\n")
+	for _, m := range mdPattern.FindAllStringSubmatchIndex(input, -1) {
+		switch {
+		case m[2] >= 0 || m[4] >= 0:
+			code := input[m[0]:m[1]]
+			if strings.Contains(code, `\`) {
+				return fmt.Errorf("backslash inside code %q", truncate(code, 40))
+			}
+		case m[16] >= 0:
+			if strings.TrimSpace(input[m[18]:m[19]]) == "" {
+				return fmt.Errorf("link %q has no URL", truncate(input[m[16]:m[17]], 40))
+			}
+			if strings.Contains(input[m[18]:m[19]], `\`) {
+				return fmt.Errorf("backslash in link URL %q", truncate(input[m[18]:m[19]], 40))
+			}
+		}
+	}
+	return nil
+}
+
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,295 @@
+package botrunner
+
+// outbox.go — durable delivery of outgoing messages.
+// Handlers never call bot.Send directly: they enqueue items into bot_outbox and
+// the dispatcher below delivers them, retrying transient failures with
+// backoff, waiting out 429 flood control and keeping per-chat order.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"bot-manager/internal/db"
+)
+
+const (
+	outboxBatchSize    = 20
+	outboxIdleInterval = 5 * time.Second
+	outboxMaxAttempts  = 8
+	outboxBaseBackoff  = 5 * time.Second
+	outboxMaxBackoff   = 10 * time.Minute
+)
+
+// outbox enqueues sends for one bot and runs its dispatcher.
+type outbox struct {
+	bot      *tgbotapi.BotAPI
+	botID    string
+	database Database
+	store    Objects
+	logger   *log.Logger
+	stats    *runStats
+	wake     chan struct{}
+}
+
+func newOutbox(
+	bot *tgbotapi.BotAPI,
+	botID string,
+	database Database,
+	store Objects,
+	logger *log.Logger,
+	stats *runStats,
+) *outbox {
+	return &outbox{
+		bot:      bot,
+		botID:    botID,
+		database: database,
+		store:    store,
+		logger:   logger,
+		stats:    stats,
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// enqueue persists items for delivery in the given order and wakes the
+// dispatcher.
+func (o *outbox) enqueue(ctx context.Context, items ...db.OutboxItem) {
+	for i := range items {
+		items[i].BotID = o.botID
+	}
+	if err := o.database.EnqueueOutbox(ctx, items...); err != nil {
+		o.logger.Printf("outbox enqueue: %v", err)
+		return
+	}
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+func marshalMarkup(markup interface{}) json.RawMessage {
+	if markup == nil {
+		return nil
+	}
+	b, _ := json.Marshal(markup)
+	return b
+}
+
+// run delivers due items until ctx is cancelled.
+func (o *outbox) run(ctx context.Context) {
+	for {
+		o.dispatch(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-o.wake:
+		case <-time.After(outboxIdleInterval):
+		}
+	}
+}
+
+func (o *outbox) dispatch(ctx context.Context) {
+	for ctx.Err() == nil {
+		items, err := o.database.DueOutbox(ctx, o.botID, outboxBatchSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				o.logger.Printf("outbox load: %v", err)
+			}
+			return
+		}
+		if len(items) == 0 {
+			return
+		}
+		for _, it := range items {
+			if ctx.Err() != nil {
+				return
+			}
+			o.deliver(ctx, it)
+		}
+	}
+}
+
+// deliver sends one item and records the outcome.
+func (o *outbox) deliver(ctx context.Context, it db.OutboxItem) {
+	o.stats.sending(true)
+	err := o.send(ctx, it)
+	o.stats.sending(false)
+	if ctx.Err() != nil {
+		return // stopped mid-send: leave the item pending for the next start
+	}
+	if err == nil {
+		o.stats.delivered()
+		if err := o.database.CompleteOutboxItem(ctx, it.ID); err != nil {
+			o.logger.Printf("outbox complete %d: %v", it.ID, err)
+		}
+		return
+	}
+
+	o.stats.failed()
+	retryAfter, permanent := classifySendError(err)
+	attempts := it.Attempts
+	switch {
+	case retryAfter > 0:
+		// Flood control is not the item's fault — don't count it as an attempt.
+		o.logger.Printf("outbox %d: 429, повтор через %s", it.ID, retryAfter)
+		o.reschedule(ctx, it, attempts, retryAfter, err)
+		return
+	case !permanent:
+		attempts++
+		if attempts < outboxMaxAttempts {
+			delay := backoff(attempts)
+			o.logger.Printf("outbox %d: %v — попытка %d, повтор через %s", it.ID, err, attempts, delay)
+			o.reschedule(ctx, it, attempts, delay, err)
+			return
+		}
+	default:
+		attempts++
+	}
+
+	o.logger.Printf("outbox %d: %v — перемещено в dead letter (%d попыток)", it.ID, err, attempts)
+	if err := o.database.DeadLetterOutboxItem(ctx, it.ID, attempts, err.Error()); err != nil {
+		o.logger.Printf("outbox dead-letter %d: %v", it.ID, err)
+	}
+}
+
+func (o *outbox) reschedule(ctx context.Context, it db.OutboxItem, attempts int, delay time.Duration, sendErr error) {
+	if err := o.database.RetryOutboxItem(ctx, it.ID, attempts, time.Now().Add(delay), sendErr.Error()); err != nil {
+		o.logger.Printf("outbox reschedule %d: %v", it.ID, err)
+	}
+}
+
+func backoff(attempt int) time.Duration {
+	d := outboxBaseBackoff << (attempt - 1)
+	if d <= 0 || d > outboxMaxBackoff {
+		return outboxMaxBackoff
+	}
+	return d
+}
+
+// classifySendError decides how a failed send is retried: after retryAfter
+// for flood control, with backoff for transient errors, or not at all.
+func classifySendError(err error) (retryAfter time.Duration, permanent bool) {
+	var tgErr *tgbotapi.Error
+	if !errors.As(err, &tgErr) {
+		// Network errors, MinIO reads and the like.
+		return 0, false
+	}
+	if tgErr.RetryAfter > 0 {
+		return time.Duration(tgErr.RetryAfter) * time.Second, false
+	}
+	if tgErr.Code >= 500 {
+		return 0, false
+	}
+	if tgErr.Code == 0 {
+		// Multipart uploads don't report the error code.
+		msg := strings.ToLower(tgErr.Message)
+		if strings.Contains(msg, "internal server error") || strings.Contains(msg, "bad gateway") {
+			return 0, false
+		}
+	}
+	return 0, true
+}
+
+func isParseError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "can't parse entities")
+}
+
+func (o *outbox) send(ctx context.Context, it db.OutboxItem) error {
+	var markup interface{}
+	if len(it.ReplyMarkup) > 0 {
+		var kb tgbotapi.InlineKeyboardMarkup
+		if err := json.Unmarshal(it.ReplyMarkup, &kb); err != nil {
+			return fmt.Errorf("decode reply markup: %w", err)
+		}
+		markup = kb
+	}
+
+	switch it.Kind {
+	case db.OutboxMessage:
+		return o.sendMessage(it.ChatID, it.Text, markup)
+	case db.OutboxPhoto:
+		return o.sendPhoto(ctx, it, markup)
+	case db.OutboxDocument:
+		return o.sendDocument(ctx, it)
+	default:
+		return fmt.Errorf("unknown outbox item kind %q", it.Kind)
+	}
+}
+
+// sendMessage converts Markdown to Telegram MarkdownV2 and sends the message.
+// On parse error, retries as plain text using the original (normalised) content.
+func (o *outbox) sendMessage(chatID int64, text string, markup interface{}) error {
+	msg := tgbotapi.NewMessage(chatID, mdToTelegramV2(text))
+	msg.ParseMode = tgbotapi.ModeMarkdownV2
+	msg.ReplyMarkup = markup
+	_, err := o.bot.Send(msg)
+	if isParseError(err) {
+		o.logger.Printf("MarkdownV2 parse error (отправляю как plain text): %v", err)
+		msg.ParseMode = ""
+		msg.Text = strings.ReplaceAll(text, `\n`, "\n")
+		_, err = o.bot.Send(msg)
+	}
+	return err
+}
+
+// sendPhoto sends the welcome image with its caption. If the image is gone or
+// Telegram rejects it for good, the caption is sent as a text message instead.
+func (o *outbox) sendPhoto(ctx context.Context, it db.OutboxItem, markup interface{}) error {
+	err := o.sendPhotoWithCaption(ctx, it, markup, true)
+	if isParseError(err) {
+		err = o.sendPhotoWithCaption(ctx, it, markup, false)
+	}
+	if err == nil {
+		return nil
+	}
+	if _, permanent := classifySendError(err); !permanent && !errors.Is(err, errObjectMissing) {
+		return err
+	}
+	o.logger.Printf("send photo: %v — fallback to text", err)
+	return o.sendMessage(it.ChatID, it.Text, markup)
+}
+
+var errObjectMissing = errors.New("object missing")
+
+func (o *outbox) sendPhotoWithCaption(ctx context.Context, it db.OutboxItem, markup interface{}, markdown bool) error {
+	rc, _, err := o.store.GetObject(ctx, it.FileKey)
+	if err != nil {
+		return fmt.Errorf("%w: get object %s: %v", errObjectMissing, it.FileKey, err)
+	}
+	defer rc.Close()
+
+	photo := tgbotapi.NewPhoto(it.ChatID, tgbotapi.FileReader{
+		Name:   it.FileName,
+		Reader: rc,
+	})
+	if markdown {
+		photo.Caption = mdToTelegramV2(it.Text)
+		photo.ParseMode = tgbotapi.ModeMarkdownV2
+	} else {
+		photo.Caption = it.Text
+	}
+	photo.ReplyMarkup = markup
+	_, err = o.bot.Send(photo)
+	return err
+}
+
+func (o *outbox) sendDocument(ctx context.Context, it db.OutboxItem) error {
+	rc, _, err := o.store.GetObject(ctx, it.FileKey)
+	if err != nil {
+		return fmt.Errorf("get object %s: %w", it.FileKey, err)
+	}
+	defer rc.Close()
+
+	doc := tgbotapi.NewDocument(it.ChatID, tgbotapi.FileReader{
+		Name:   it.FileName,
+		Reader: rc,
+	})
+	_, err = o.bot.Send(doc)
+	return err
+}
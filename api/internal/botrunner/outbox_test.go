@@ -0,0 +1,85 @@
+package botrunner
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"bot-manager/internal/db"
+)
+
+func TestBackoff(t *testing.T) {
+	for _, tc := range []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 5 * time.Second},
+		{2, 10 * time.Second},
+		{3, 20 * time.Second},
+		{7, 320 * time.Second},
+		{8, outboxMaxBackoff},
+		{100, outboxMaxBackoff}, // the shift overflows
+	} {
+		if got := backoff(tc.attempt); got != tc.want {
+			t.Errorf("backoff(%d) = %s, want %s", tc.attempt, got, tc.want)
+		}
+	}
+}
+
+func TestClassifySendError(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		err        error
+		retryAfter time.Duration
+		permanent  bool
+	}{
+		{"network", errors.New("dial tcp: connection refused"), 0, false},
+		{"flood control", &tgbotapi.Error{Code: 429, Message: "Too Many Requests",
+			ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 3}}, 3 * time.Second, false},
+		{"server error", &tgbotapi.Error{Code: 502, Message: "Bad Gateway"}, 0, false},
+		{"blocked", &tgbotapi.Error{Code: 403, Message: "Forbidden: bot was blocked by the user"}, 0, true},
+		{"bad request", &tgbotapi.Error{Code: 400, Message: "Bad Request: chat not found"}, 0, true},
+		{"upload server error", &tgbotapi.Error{Message: "Internal Server Error"}, 0, false},
+		{"upload bad request", &tgbotapi.Error{Message: "Bad Request: wrong file"}, 0, true},
+	} {
+		retryAfter, permanent := classifySendError(tc.err)
+		if retryAfter != tc.retryAfter || permanent != tc.permanent {
+			t.Errorf("%s: classifySendError = %s, %v; want %s, %v", tc.name, retryAfter, permanent, tc.retryAfter, tc.permanent)
+		}
+	}
+}
+
+func TestTransientSendErrorIsRetried(t *testing.T) {
+	h := newHarness(t)
+	h.tg.Fail("sendMessage", 502, "Bad Gateway", 1)
+	h.start(t, testBot())
+
+	h.tg.SendText(testUser, "/start")
+	eventually(t, "a failed attempt", func() bool {
+		items := h.outbox(db.OutboxPending)
+		return len(items) == 1 && items[0].Attempts == 1
+	})
+	item := h.outbox(db.OutboxPending)[0]
+	if !strings.Contains(item.LastError, "Bad Gateway") || time.Until(item.NextAttemptAt) < outboxBaseBackoff/2 {
+		t.Errorf("retried item = %+v", item)
+	}
+}
+
+func TestFloodWaitIsNotAnAttempt(t *testing.T) {
+	h := newHarness(t)
+	h.tg.FloodWait("sendMessage", 30, 1)
+	h.start(t, testBot())
+
+	h.tg.SendText(testUser, "/start")
+	eventually(t, "the item to be rescheduled", func() bool {
+		items := h.outbox(db.OutboxPending)
+		return len(items) == 1 && items[0].LastError != ""
+	})
+	item := h.outbox(db.OutboxPending)[0]
+	if item.Attempts != 0 || time.Until(item.NextAttemptAt) < 25*time.Second {
+		t.Errorf("item after flood control = %+v, want no attempt counted and a 30s wait", item)
+	}
+}
@@ -2,14 +2,15 @@ package botrunner
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"strings"
 	"sync"
 	"time"
 
 	"bot-manager/internal/db"
-	"bot-manager/internal/storage"
 )
 
 type BotStatus string
@@ -19,38 +20,66 @@ const (
 	StatusStarting BotStatus = "starting"
 	StatusRunning  BotStatus = "running"
 	StatusError    BotStatus = "error"
+	// StatusConflict means Telegram refused getUpdates with 409: another
+	// process is polling the same token or a webhook is set.
+	StatusConflict BotStatus = "conflict"
+	// StatusDegraded means the bot is running but the watchdog found its
+	// polling or message handling stuck.
+	StatusDegraded BotStatus = "degraded"
+)
+
+const (
+	errorRetryDelay       = 10 * time.Second
+	conflictRetryDelay    = time.Minute
+	maxConflictRetryDelay = 10 * time.Minute
 )
 
 // BotRunner owns a single bot goroutine and its associated log buffer.
 type BotRunner struct {
-	Cfg   db.Bot
-	Logs  *RingBuffer
-	store *storage.MinioStore
+	Cfg      db.Bot
+	Logs     *RingBuffer
+	database Database
+	store    Objects
+	opts     Options
+	stats    runStats
 
 	mu        sync.RWMutex
 	status    BotStatus
 	statusMsg string
 	cancel    context.CancelFunc
 	done      chan struct{}
+	retry     chan struct{} // wakes a failed run loop for an immediate retry
 }
 
-func New(cfg db.Bot, store *storage.MinioStore) *BotRunner {
+func New(cfg db.Bot, database Database, store Objects, opts Options) *BotRunner {
 	return &BotRunner{
-		Cfg:    cfg,
-		Logs:   NewRingBuffer(),
-		store:  store,
-		status: StatusStopped,
+		Cfg:      cfg,
+		Logs:     NewRingBuffer(),
+		database: database,
+		store:    store,
+		opts:     opts,
+		status:   StatusStopped,
+		retry:    make(chan struct{}, 1),
 	}
 }
 
 func (r *BotRunner) Start() error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	if r.status != StatusStopped && r.status != StatusError {
+	if r.status == StatusError || r.status == StatusConflict {
+		// The run loop is still alive and waiting to retry — retry now.
+		select {
+		case r.retry <- struct{}{}:
+		default:
+		}
+		return nil
+	}
+	if r.status != StatusStopped {
 		return fmt.Errorf("bot is already %s", r.status)
 	}
 	ctx, cancel := context.WithCancel(context.Background())
 	r.cancel = cancel
+	r.stats.reset()
 	r.done = make(chan struct{})
 	r.setStatusLocked(StatusStarting, "")
 	go r.run(ctx)
@@ -91,6 +120,34 @@ func (r *BotRunner) StatusMsg() string {
 	return r.statusMsg
 }
 
+// Stats returns the runner's counters since the last Start.
+func (r *BotRunner) Stats() Stats {
+	return r.stats.snapshot()
+}
+
+// Heartbeat returns progress timestamps of the polling and delivery loops.
+func (r *BotRunner) Heartbeat() Heartbeat {
+	return r.stats.heartbeat()
+}
+
+// SetDegraded flags a running bot as degraded with the given reason, or
+// clears the flag when reason is empty.
+func (r *BotRunner) SetDegraded(reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch {
+	case reason != "" && (r.status == StatusRunning || r.status == StatusDegraded):
+		r.setStatusLocked(StatusDegraded, reason)
+	case reason == "" && r.status == StatusDegraded:
+		r.setStatusLocked(StatusRunning, "")
+	}
+}
+
+// Logf writes a line to the bot's log.
+func (r *BotRunner) Logf(format string, args ...interface{}) {
+	r.botLogger().Printf(format, args...)
+}
+
 func (r *BotRunner) UpdateConfig(cfg db.Bot) {
 	r.mu.Lock()
 	r.Cfg = cfg
@@ -100,32 +157,53 @@ func (r *BotRunner) UpdateConfig(cfg db.Bot) {
 func (r *BotRunner) run(ctx context.Context) {
 	defer close(r.done)
 	logger := r.botLogger()
+	conflictDelay := conflictRetryDelay
 
 	for {
 		r.setStatus(StatusRunning, "")
-		err := runBot(ctx, r.Cfg, r.store, logger)
+		err := runBot(ctx, r.Cfg, r.opts.Connection.For(r.Cfg), r.database, r.store, logger, r.emit, &r.stats)
 
 		if ctx.Err() != nil {
-			r.setStatus(StatusStopped, "")
+			r.stopped()
 			return
 		}
 
-		errMsg := ""
-		if err != nil {
-			errMsg = err.Error()
+		r.stats.failed()
+		delay := errorRetryDelay
+		var conflict *ConflictError
+		if errors.As(err, &conflict) {
+			// Another poller holds the token; retrying quickly only produces
+			// more 409s, so back off progressively.
+			delay = conflictDelay
+			conflictDelay = min(conflictDelay*2, maxConflictRetryDelay)
+			r.setStatus(StatusConflict, err.Error())
+			logger.Printf("Конфликт токена: %v. Повтор через %s...", err, delay)
+		} else {
+			conflictDelay = conflictRetryDelay
+			errMsg := ""
+			if err != nil {
+				errMsg = err.Error()
+			}
+			r.setStatus(StatusError, errMsg)
+			logger.Printf("Бот упал: %v. Перезапуск через %s...", err, delay)
 		}
-		r.setStatus(StatusError, errMsg)
-		logger.Printf("Бот упал: %v. Перезапуск через 10 сек...", err)
 
 		select {
 		case <-ctx.Done():
-			r.setStatus(StatusStopped, "")
+			r.stopped()
 			return
-		case <-time.After(10 * time.Second):
+		case <-r.retry:
+		case <-time.After(delay):
 		}
+		r.stats.restarted()
 	}
 }
 
+func (r *BotRunner) stopped() {
+	r.stats.stopped()
+	r.setStatus(StatusStopped, "")
+}
+
 func (r *BotRunner) setStatus(s BotStatus, msg string) {
 	r.mu.Lock()
 	r.setStatusLocked(s, msg)
@@ -133,20 +211,28 @@ func (r *BotRunner) setStatus(s BotStatus, msg string) {
 }
 
 func (r *BotRunner) setStatusLocked(s BotStatus, msg string) {
+	if r.status == s && r.statusMsg == msg {
+		return
+	}
 	r.status = s
 	r.statusMsg = msg
+	r.emit(Event{Type: EventStatus, Status: s, StatusMsg: msg})
 }
 
-type ringWriter struct{ buf *RingBuffer }
+// ringWriter stores log output in the runner's ring buffer and publishes
+// each line as a log event.
+type ringWriter struct{ r *BotRunner }
 
 func (w *ringWriter) Write(p []byte) (n int, err error) {
-	w.buf.Write(string(p))
+	line := string(p)
+	w.r.Logs.Write(line)
+	w.r.emit(Event{Type: EventLog, Line: strings.TrimRight(line, "\n")})
 	return len(p), nil
 }
 
 func (r *BotRunner) botLogger() *log.Logger {
 	return log.New(
-		io.MultiWriter(&ringWriter{r.Logs}, log.Writer()),
+		io.MultiWriter(&ringWriter{r}, log.Writer()),
 		fmt.Sprintf("[%s] ", r.Cfg.ID),
 		log.LstdFlags,
 	)
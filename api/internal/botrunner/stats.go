@@ -0,0 +1,121 @@
+package botrunner
+
+import (
+	"sync"
+	"time"
+)
+
+// Stats is a point-in-time copy of a runner's counters. Counters cover the
+// time since the last Start.
+type Stats struct {
+	StartedAt    time.Time // zero while stopped
+	LastUpdateAt time.Time // last update received from Telegram
+	Username     string    // Telegram @username, known once authorised
+	Restarts     int       // automatic restarts after a failure
+	Updates      int64     // updates received
+	Callbacks    int64     // callback queries received
+	Deliveries   int64     // outbox items delivered
+	Errors       int64     // failed polls, failed send attempts and crashes
+}
+
+// Heartbeat shows whether a runner's loops are making progress. Zero
+// "…StartedAt" fields mean the corresponding operation is not in progress.
+type Heartbeat struct {
+	LastPollAt       time.Time // last completed getUpdates (or start of the run)
+	HandlerStartedAt time.Time // update handler in progress since
+	SendStartedAt    time.Time // outbox delivery in progress since
+}
+
+// runStats collects Stats and the Heartbeat for a runner; runBot and the
+// outbox write to it.
+type runStats struct {
+	mu sync.Mutex
+	s  Stats
+	hb Heartbeat
+}
+
+func (st *runStats) reset() {
+	st.mu.Lock()
+	st.s = Stats{StartedAt: time.Now()}
+	st.hb = Heartbeat{}
+	st.mu.Unlock()
+}
+
+func (st *runStats) stopped() {
+	st.mu.Lock()
+	st.s.StartedAt = time.Time{}
+	st.mu.Unlock()
+}
+
+func (st *runStats) authorized(username string) {
+	st.mu.Lock()
+	st.s.Username = username
+	st.mu.Unlock()
+}
+
+func (st *runStats) update(callback bool) {
+	st.mu.Lock()
+	st.s.LastUpdateAt = time.Now()
+	st.s.Updates++
+	if callback {
+		st.s.Callbacks++
+	}
+	st.mu.Unlock()
+}
+
+func (st *runStats) delivered() {
+	st.mu.Lock()
+	st.s.Deliveries++
+	st.mu.Unlock()
+}
+
+func (st *runStats) failed() {
+	st.mu.Lock()
+	st.s.Errors++
+	st.mu.Unlock()
+}
+
+func (st *runStats) restarted() {
+	st.mu.Lock()
+	st.s.Restarts++
+	st.mu.Unlock()
+}
+
+func (st *runStats) snapshot() Stats {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.s
+}
+
+func (st *runStats) polled() {
+	st.mu.Lock()
+	st.hb.LastPollAt = time.Now()
+	st.mu.Unlock()
+}
+
+// handling marks the start (true) or end (false) of an update handler.
+func (st *runStats) handling(busy bool) {
+	st.mu.Lock()
+	st.hb.HandlerStartedAt = busySince(busy)
+	st.mu.Unlock()
+}
+
+// sending marks the start (true) or end (false) of an outbox delivery.
+func (st *runStats) sending(busy bool) {
+	st.mu.Lock()
+	st.hb.SendStartedAt = busySince(busy)
+	st.mu.Unlock()
+}
+
+func busySince(busy bool) time.Time {
+	if busy {
+		return time.Now()
+	}
+	return time.Time{}
+}
+
+func (st *runStats) heartbeat() Heartbeat {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.hb
+}
@@ -0,0 +1,33 @@
+package botrunner
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+
+	"bot-manager/internal/db"
+)
+
+// Database is the part of the database a running bot uses: its update
+// offset, its outbox and the chats it has seen. Any db.Store, such as *db.DB
+// or *db.MemStore, implements it.
+type Database interface {
+	GetUpdateOffset(ctx context.Context, botID string) (updateID int, ok bool, err error)
+	SetUpdateOffset(ctx context.Context, botID, tokenBotID string, updateID int) error
+	UpsertBotChat(ctx context.Context, c db.BotChat) error
+
+	EnqueueOutbox(ctx context.Context, items ...db.OutboxItem) error
+	DueOutbox(ctx context.Context, botID string, limit int) ([]db.OutboxItem, error)
+	CompleteOutboxItem(ctx context.Context, id int64) error
+	RetryOutboxItem(ctx context.Context, id int64, attempts int, next time.Time, lastErr string) error
+	DeadLetterOutboxItem(ctx context.Context, id int64, attempts int, lastErr string) error
+}
+
+// Objects is the asset storage a running bot reads from.
+// *storage.MinioStore implements it.
+type Objects interface {
+	GetObject(ctx context.Context, key string) (io.ReadCloser, *minio.ObjectInfo, error)
+	ListObjects(ctx context.Context, prefix string) ([]minio.ObjectInfo, error)
+}
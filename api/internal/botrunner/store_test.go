@@ -0,0 +1,34 @@
+package botrunner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// memObjects is an in-memory Objects.
+type memObjects map[string][]byte
+
+func (o memObjects) GetObject(_ context.Context, key string) (io.ReadCloser, *minio.ObjectInfo, error) {
+	data, ok := o[key]
+	if !ok {
+		return nil, nil, fmt.Errorf("object %s not found", key)
+	}
+	return io.NopCloser(bytes.NewReader(data)), &minio.ObjectInfo{Key: key, Size: int64(len(data))}, nil
+}
+
+func (o memObjects) ListObjects(_ context.Context, prefix string) ([]minio.ObjectInfo, error) {
+	var out []minio.ObjectInfo
+	for key, data := range o {
+		if strings.HasPrefix(key, prefix) {
+			out = append(out, minio.ObjectInfo{Key: key, Size: int64(len(data))})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out, nil
+}
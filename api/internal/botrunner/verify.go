@@ -0,0 +1,98 @@
+package botrunner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// VerifyParams is what Verify checks: a token and the channel the bot guards.
+type VerifyParams struct {
+	Token      string
+	ChannelID  int64
+	Connection Connection
+}
+
+// VerifyResult reports what Telegram says about a token and channel.
+// Problems are listed in Errors; OK is true when there are none.
+type VerifyResult struct {
+	OK            bool     `json:"ok"`
+	BotID         int64    `json:"bot_id,omitempty"`
+	BotUsername   string   `json:"bot_username,omitempty"`
+	ChatTitle     string   `json:"chat_title,omitempty"`
+	ChatType      string   `json:"chat_type,omitempty"`
+	ChatUsername  string   `json:"chat_username,omitempty"`
+	BotStatus     string   `json:"bot_status,omitempty"` // the bot's member status in the channel
+	MissingRights []string `json:"missing_rights"`
+	Errors        []string `json:"errors"`
+}
+
+// adminRights are the administrator rights the bot needs in the channel, by
+// their Bot API names.
+var adminRights = []struct {
+	name string
+	has  func(tgbotapi.ChatMember) bool
+}{
+	// Looking up members, which checking subscriptions does.
+	{"can_manage_chat", func(m tgbotapi.ChatMember) bool { return m.CanManageChat }},
+	// Invite links the bot created stop working when it loses this right.
+	{"can_invite_users", func(m tgbotapi.ChatMember) bool { return m.CanInviteUsers }},
+}
+
+// Verify calls getMe with the token, getChat on the channel and getChatMember
+// for the bot itself. The bot has to be an administrator of the channel with
+// the adminRights, otherwise Telegram refuses the getChatMember calls used to
+// check subscriptions. Each missing right is listed in MissingRights.
+func Verify(ctx context.Context, p VerifyParams) VerifyResult {
+	res := VerifyResult{MissingRights: []string{}, Errors: []string{}}
+
+	bot, err := newBotAPI(ctx, p.Token, p.Connection)
+	if err != nil {
+		res.Errors = append(res.Errors, fmt.Sprintf("token rejected by Telegram: %v", err))
+		return res
+	}
+	res.BotID = bot.Self.ID
+	res.BotUsername = bot.Self.UserName
+
+	chat, err := bot.GetChat(tgbotapi.ChatInfoConfig{
+		ChatConfig: tgbotapi.ChatConfig{ChatID: p.ChannelID},
+	})
+	if err != nil {
+		res.Errors = append(res.Errors, fmt.Sprintf("getChat %d: %v (is the bot a member of the channel?)", p.ChannelID, err))
+		return res
+	}
+	res.ChatTitle = chat.Title
+	res.ChatType = chat.Type
+	res.ChatUsername = chat.UserName
+
+	member, err := bot.GetChatMember(tgbotapi.GetChatMemberConfig{
+		ChatConfigWithUser: tgbotapi.ChatConfigWithUser{ChatID: p.ChannelID, UserID: bot.Self.ID},
+	})
+	if err != nil {
+		res.Errors = append(res.Errors, fmt.Sprintf("getChatMember: %v", err))
+		return res
+	}
+	res.BotStatus = member.Status
+	switch {
+	case member.IsCreator():
+		// The creator has every right.
+	case !member.IsAdministrator():
+		res.MissingRights = append(res.MissingRights, "administrator")
+		res.Errors = append(res.Errors, "the bot is not an administrator of the channel, so it cannot check subscriptions")
+	default:
+		for _, r := range adminRights {
+			if !r.has(member) {
+				res.MissingRights = append(res.MissingRights, r.name)
+			}
+		}
+		if len(res.MissingRights) > 0 {
+			res.Errors = append(res.Errors, fmt.Sprintf("the bot lacks administrator rights in the channel: %s",
+				strings.Join(res.MissingRights, ", ")))
+		}
+	}
+
+	res.OK = len(res.Errors) == 0
+	return res
+}
@@ -0,0 +1,105 @@
+package botrunner
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"bot-manager/internal/tgtest"
+)
+
+func TestVerify(t *testing.T) {
+	tg := tgtest.NewServer(testToken)
+	defer tg.Close()
+	tg.SetChat(tgbotapi.Chat{ID: testChannel, Type: "channel", Title: "News"})
+	conn := Connection{Endpoint: tg.URL}
+	p := VerifyParams{Token: testToken, ChannelID: testChannel, Connection: conn}
+
+	res := Verify(context.Background(), p)
+	if res.OK || len(res.MissingRights) != 1 || res.ChatTitle != "News" {
+		t.Fatalf("bot not admin: %+v", res)
+	}
+
+	tg.SetMember(testChannel, tg.Bot.ID, "administrator")
+	if res := Verify(context.Background(), p); !res.OK || res.BotUsername != "test_bot" {
+		t.Fatalf("bot admin: %+v", res)
+	}
+
+	tg.SetMember(testChannel, tg.Bot.ID, "creator")
+	if res := Verify(context.Background(), p); !res.OK {
+		t.Fatalf("bot creator: %+v", res)
+	}
+}
+
+func TestVerifyAdminRights(t *testing.T) {
+	tg := tgtest.NewServer(testToken)
+	defer tg.Close()
+	tg.SetChat(tgbotapi.Chat{ID: testChannel, Type: "channel", Title: "News"})
+	p := VerifyParams{Token: testToken, ChannelID: testChannel, Connection: Connection{Endpoint: tg.URL}}
+
+	for _, tc := range []struct {
+		name   string
+		member tgbotapi.ChatMember
+		want   []string
+	}{
+		{"all rights", tgbotapi.ChatMember{Status: "administrator", CanManageChat: true, CanInviteUsers: true}, nil},
+		{"no invite", tgbotapi.ChatMember{Status: "administrator", CanManageChat: true, CanPostMessages: true}, []string{"can_invite_users"}},
+		{"no rights", tgbotapi.ChatMember{Status: "administrator"}, []string{"can_manage_chat", "can_invite_users"}},
+		{"member", tgbotapi.ChatMember{Status: "member"}, []string{"administrator"}},
+	} {
+		tc.member.User = &tgbotapi.User{ID: tg.Bot.ID}
+		tg.SetChatMember(testChannel, tc.member)
+		res := Verify(context.Background(), p)
+		if strings.Join(res.MissingRights, ",") != strings.Join(tc.want, ",") || res.OK != (len(tc.want) == 0) {
+			t.Errorf("%s: %+v, want missing %v", tc.name, res, tc.want)
+		}
+	}
+}
+
+func TestParseChatRef(t *testing.T) {
+	tests := []struct {
+		ref      string
+		id       int64
+		username string
+	}{
+		{"@news_channel", 0, "@news_channel"},
+		{"news_channel", 0, "@news_channel"},
+		{"https://t.me/news_channel", 0, "@news_channel"},
+		{"t.me/news_channel/123", 0, "@news_channel"},
+		{"https://t.me/s/news_channel", 0, "@news_channel"},
+		{"https://t.me/c/1234567890/5", -1001234567890, ""},
+		{"-1001234567890", -1001234567890, ""},
+	}
+	for _, tt := range tests {
+		id, username, err := ParseChatRef(tt.ref)
+		if err != nil || id != tt.id || username != tt.username {
+			t.Errorf("ParseChatRef(%q) = %d, %q, %v", tt.ref, id, username, err)
+		}
+	}
+
+	for _, ref := range []string{"", "a b", "https://t.me/+AbCdEf", "https://t.me/joinchat/AbCdEf"} {
+		if _, _, err := ParseChatRef(ref); err == nil {
+			t.Errorf("ParseChatRef(%q) succeeded", ref)
+		}
+	}
+	if _, _, err := ParseChatRef("https://t.me/+AbCdEf"); !errors.Is(err, ErrInviteLink) {
+		t.Errorf("invite link error = %v", err)
+	}
+}
+
+func TestResolveChat(t *testing.T) {
+	tg := tgtest.NewServer(testToken)
+	defer tg.Close()
+	tg.SetChat(tgbotapi.Chat{ID: testChannel, Type: "channel", Title: "News", UserName: "news_channel"})
+
+	chat, err := ResolveChat(context.Background(), testToken, Connection{Endpoint: tg.URL}, "https://t.me/news_channel")
+	if err != nil || chat.ID != testChannel || chat.Title != "News" {
+		t.Fatalf("ResolveChat = %+v, %v", chat, err)
+	}
+	if _, err := ResolveChat(context.Background(), testToken, Connection{Endpoint: tg.URL}, "@unknown_chan"); err == nil {
+		t.Error("unknown chat resolved")
+	}
+}
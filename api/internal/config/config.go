@@ -5,6 +5,10 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"time"
+
+	"bot-manager/internal/secrets"
+	"bot-manager/internal/validation"
 )
 
 type Config struct {
@@ -17,7 +21,26 @@ type Config struct {
 	MinioUseSSL   bool
 	AdminUsername string
 	AdminPassword string // plaintext, used only if no bcrypt hash stored
-	SessionSecret []byte // 32 bytes for HMAC-SHA256
+	SessionSecret []byte // 32 bytes, HMAC-SHA256 key for session token hashes
+	InstanceID    string // this replica's name in bot leases
+	InstanceURL   string // base URL other replicas use to reach this one
+	LeaseTTL      time.Duration
+
+	WatchdogInterval     time.Duration // 0 disables the watchdog
+	WatchdogPollStall    time.Duration
+	WatchdogHandlerStall time.Duration
+	WatchdogAutoRestart  bool
+
+	// TokenKeys encrypts bot tokens at rest; nil when TOKEN_ENCRYPTION_KEYS
+	// is unset.
+	TokenKeys *secrets.Keyring
+
+	// TelegramAPIEndpoint is the Bot API server used by bots without their
+	// own; "" means api.telegram.org.
+	TelegramAPIEndpoint string
+	// TelegramProxy is the proxy for Telegram traffic of bots without their
+	// own; "" connects directly (or through HTTPS_PROXY).
+	TelegramProxy string
 }
 
 func Load() (*Config, error) {
@@ -30,10 +53,44 @@ func Load() (*Config, error) {
 		MinioBucket:   getenv("MINIO_BUCKET", ""),
 		AdminUsername: getenv("ADMIN_USERNAME", "admin"),
 		AdminPassword: getenv("ADMIN_PASSWORD", "changeme"),
+		InstanceURL:   getenv("INSTANCE_URL", ""),
+		LeaseTTL:      time.Duration(getenvInt("LEASE_TTL_SECONDS", 30)) * time.Second,
+	}
+
+	hostname, _ := os.Hostname()
+	c.InstanceID = getenv("INSTANCE_ID", hostname)
+	if c.InstanceID == "" {
+		return nil, fmt.Errorf("INSTANCE_ID is required when the hostname is unknown")
+	}
+	if c.LeaseTTL < 3*time.Second {
+		return nil, fmt.Errorf("LEASE_TTL_SECONDS must be at least 3")
 	}
 
 	c.MinioUseSSL = getenv("MINIO_USE_SSL", "false") == "true"
 
+	c.WatchdogInterval = time.Duration(getenvInt("WATCHDOG_INTERVAL_SECONDS", 15)) * time.Second
+	c.WatchdogPollStall = time.Duration(getenvInt("WATCHDOG_POLL_STALL_SECONDS", 180)) * time.Second
+	c.WatchdogHandlerStall = time.Duration(getenvInt("WATCHDOG_HANDLER_STALL_SECONDS", 120)) * time.Second
+	c.WatchdogAutoRestart = getenv("WATCHDOG_AUTO_RESTART", "false") == "true"
+	if c.WatchdogInterval > 0 && c.WatchdogPollStall <= 60*time.Second {
+		return nil, fmt.Errorf("WATCHDOG_POLL_STALL_SECONDS must exceed the 60s long-poll timeout")
+	}
+
+	keys, err := secrets.ParseKeyring(getenv("TOKEN_ENCRYPTION_KEYS", ""))
+	if err != nil {
+		return nil, fmt.Errorf("TOKEN_ENCRYPTION_KEYS: %w", err)
+	}
+	c.TokenKeys = keys
+
+	c.TelegramAPIEndpoint = getenv("TELEGRAM_API_ENDPOINT", "")
+	if c.TelegramAPIEndpoint != "" && !validation.ValidEndpoint(c.TelegramAPIEndpoint) {
+		return nil, fmt.Errorf("TELEGRAM_API_ENDPOINT must be an http:// or https:// URL")
+	}
+	c.TelegramProxy = getenv("TELEGRAM_PROXY", "")
+	if c.TelegramProxy != "" && !validation.ValidProxy(c.TelegramProxy) {
+		return nil, fmt.Errorf("TELEGRAM_PROXY must be an http://, https:// or socks5:// URL with host and port")
+	}
+
 	if c.DatabaseURL == "" {
 		return nil, fmt.Errorf("DATABASE_URL is required")
 	}
@@ -68,6 +125,3 @@ func getenvInt(key string, def int) int {
 	}
 	return def
 }
-
-// Suppress unused warning
-var _ = getenvInt
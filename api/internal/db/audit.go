@@ -0,0 +1,56 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// Audit actions.
+const (
+	AuditTokenReveal  = "token.reveal"
+	AuditTokenExport  = "token.export"
+	AuditUserCreate   = "user.create"
+	AuditUserUpdate   = "user.update"
+	AuditUserDelete   = "user.delete"
+	AuditUserPassword = "user.password"
+)
+
+type AuditEntry struct {
+	ID        int64     `json:"id"`
+	Actor     string    `json:"actor"`
+	Action    string    `json:"action"`
+	Target    string    `json:"target"`
+	Detail    string    `json:"detail"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RecordAudit logs action on target, attributed to the actor in ctx.
+func (d *DB) RecordAudit(ctx context.Context, action, target, detail string) error {
+	_, err := d.Pool.Exec(ctx, `
+		INSERT INTO audit_log(actor, action, target, detail)
+		VALUES($1,$2,$3,$4)`,
+		ActorFrom(ctx), action, target, detail,
+	)
+	return err
+}
+
+// GetAuditLog returns the newest entries first.
+func (d *DB) GetAuditLog(ctx context.Context, limit int) ([]AuditEntry, error) {
+	rows, err := d.Pool.Query(ctx, `
+		SELECT id, actor, action, target, detail, created_at
+		FROM audit_log ORDER BY id DESC LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		if err := rows.Scan(&e.ID, &e.Actor, &e.Action, &e.Target, &e.Detail, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -2,7 +2,10 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -16,20 +19,49 @@ const (
 )
 
 type Bot struct {
-	ID            string    `json:"id"`
-	Name          string    `json:"name"`
-	Type          BotType   `json:"type"`
-	Token         string    `json:"token"`
-	ChannelID     int64     `json:"channel_id"`
-	InviteLink    string    `json:"invite_link"`
-	WelcomeImgKey string    `json:"welcome_img_key"`
-	WelcomeMsg    string    `json:"welcome_msg"`
-	ButtonText    string    `json:"button_text"`
-	NotSubMsg     string    `json:"not_sub_msg"`
-	SuccessMsg    string    `json:"success_msg"`
-	Enabled       bool      `json:"enabled"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	ID                 string    `json:"id"`
+	Name               string    `json:"name"`
+	Type               BotType   `json:"type"`
+	Token              string    `json:"token"`
+	ChannelID          int64     `json:"channel_id"`
+	InviteLink         string    `json:"invite_link"`
+	WelcomeImgKey      string    `json:"welcome_img_key"`
+	WelcomeMsg         string    `json:"welcome_msg"`
+	ButtonText         string    `json:"button_text"`
+	NotSubMsg          string    `json:"not_sub_msg"`
+	SuccessMsg         string    `json:"success_msg"`
+	Enabled            bool      `json:"enabled"`
+	StaleUpdateMinutes int       `json:"stale_update_minutes"` // skip updates older than this after downtime; 0 = never
+	Tags               []string  `json:"tags"`
+	APIEndpoint        string    `json:"api_endpoint"` // Bot API server base URL; "" = global default
+	Proxy              string    `json:"proxy"`        // proxy URL for Telegram traffic; "" = global default, "direct" = none
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+
+	// TokenBotID is the "<id>:" part of the plaintext token, set by the
+	// writer since the stored token is encrypted. It is not stored; a write
+	// that carries it drops an update offset recorded for another ID.
+	TokenBotID string `json:"-"`
+}
+
+// ProxyDirect as a bot's proxy makes it bypass the global proxy.
+const ProxyDirect = "direct"
+
+// ProxyMask replaces proxy passwords in API responses and revision snapshots.
+const ProxyMask = "****"
+
+// RedactProxy returns the proxy URL raw with its password masked.
+func RedactProxy(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	if _, ok := u.User.Password(); !ok {
+		return raw
+	}
+	user := url.User(u.User.Username()).String()
+	u.User = nil
+	return strings.Replace(u.String(), "://", "://"+user+":"+ProxyMask+"@", 1)
 }
 
 type Asset struct {
@@ -42,11 +74,27 @@ type Asset struct {
 	CreatedAt   time.Time `json:"created_at"`
 }
 
+// botColumns is the column list shared by every bots SELECT; keep it in sync
+// with scanBot.
+const botColumns = `id, name, type, token, channel_id, invite_link,
+		       welcome_img_key, welcome_msg, button_text, not_sub_msg,
+		       success_msg, enabled, stale_update_minutes, tags, api_endpoint,
+		       proxy, created_at, updated_at`
+
+func scanBot(row pgx.Row) (Bot, error) {
+	var b Bot
+	err := row.Scan(
+		&b.ID, &b.Name, &b.Type, &b.Token, &b.ChannelID, &b.InviteLink,
+		&b.WelcomeImgKey, &b.WelcomeMsg, &b.ButtonText, &b.NotSubMsg,
+		&b.SuccessMsg, &b.Enabled, &b.StaleUpdateMinutes, &b.Tags, &b.APIEndpoint,
+		&b.Proxy, &b.CreatedAt, &b.UpdatedAt,
+	)
+	return b, err
+}
+
 func (d *DB) GetAllBots(ctx context.Context) ([]Bot, error) {
 	rows, err := d.Pool.Query(ctx, `
-		SELECT id, name, type, token, channel_id, invite_link,
-		       welcome_img_key, welcome_msg, button_text, not_sub_msg,
-		       success_msg, enabled, created_at, updated_at
+		SELECT `+botColumns+`
 		FROM bots ORDER BY created_at`)
 	if err != nil {
 		return nil, err
@@ -55,12 +103,8 @@ func (d *DB) GetAllBots(ctx context.Context) ([]Bot, error) {
 
 	var bots []Bot
 	for rows.Next() {
-		var b Bot
-		if err := rows.Scan(
-			&b.ID, &b.Name, &b.Type, &b.Token, &b.ChannelID, &b.InviteLink,
-			&b.WelcomeImgKey, &b.WelcomeMsg, &b.ButtonText, &b.NotSubMsg,
-			&b.SuccessMsg, &b.Enabled, &b.CreatedAt, &b.UpdatedAt,
-		); err != nil {
+		b, err := scanBot(rows)
+		if err != nil {
 			return nil, err
 		}
 		bots = append(bots, b)
@@ -69,40 +113,117 @@ func (d *DB) GetAllBots(ctx context.Context) ([]Bot, error) {
 }
 
 func (d *DB) GetBot(ctx context.Context, id string) (Bot, error) {
-	var b Bot
-	err := d.Pool.QueryRow(ctx, `
-		SELECT id, name, type, token, channel_id, invite_link,
-		       welcome_img_key, welcome_msg, button_text, not_sub_msg,
-		       success_msg, enabled, created_at, updated_at
-		FROM bots WHERE id=$1`, id,
-	).Scan(
-		&b.ID, &b.Name, &b.Type, &b.Token, &b.ChannelID, &b.InviteLink,
-		&b.WelcomeImgKey, &b.WelcomeMsg, &b.ButtonText, &b.NotSubMsg,
-		&b.SuccessMsg, &b.Enabled, &b.CreatedAt, &b.UpdatedAt,
-	)
+	b, err := scanBot(d.Pool.QueryRow(ctx, `
+		SELECT `+botColumns+`
+		FROM bots WHERE id=$1`, id))
 	if err == pgx.ErrNoRows {
-		return b, fmt.Errorf("bot %q not found", id)
+		return b, fmt.Errorf("bot %q %w", id, ErrNotFound)
 	}
 	return b, err
 }
 
+// ErrStale is returned by UpdateBotIfUnchanged when the bot has been modified
+// since the version the caller based its change on.
+var ErrStale = errors.New("bot was modified concurrently")
+
+// ErrBotExists is returned by CreateBot when the ID is taken.
+var ErrBotExists = errors.New("bot already exists")
+
+// UpsertBot creates or replaces a bot and records a revision of the result.
 func (d *DB) UpsertBot(ctx context.Context, b Bot) error {
-	_, err := d.Pool.Exec(ctx, `
-		INSERT INTO bots(id, name, type, token, channel_id, invite_link,
-		                 welcome_img_key, welcome_msg, button_text, not_sub_msg,
-		                 success_msg, enabled, updated_at)
-		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,NOW())
-		ON CONFLICT(id) DO UPDATE SET
-		    name=EXCLUDED.name, type=EXCLUDED.type, token=EXCLUDED.token,
-		    channel_id=EXCLUDED.channel_id, invite_link=EXCLUDED.invite_link,
-		    welcome_img_key=EXCLUDED.welcome_img_key, welcome_msg=EXCLUDED.welcome_msg,
-		    button_text=EXCLUDED.button_text, not_sub_msg=EXCLUDED.not_sub_msg,
-		    success_msg=EXCLUDED.success_msg, enabled=EXCLUDED.enabled,
-		    updated_at=NOW()`,
+	return d.upsertBot(ctx, b, time.Time{})
+}
+
+// UpdateBotIfUnchanged replaces an existing bot only if its updated_at still
+// equals version; otherwise it returns ErrStale.
+func (d *DB) UpdateBotIfUnchanged(ctx context.Context, b Bot, version time.Time) error {
+	return d.upsertBot(ctx, b, version)
+}
+
+// CreateBot stores a new bot and records its first revision. It fails with
+// ErrBotExists if the ID is taken and never touches the existing bot.
+func (d *DB) CreateBot(ctx context.Context, b Bot) error {
+	if b.Tags == nil {
+		b.Tags = []string{}
+	}
+	return pgx.BeginFunc(ctx, d.Pool, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, insertBotSQL+` ON CONFLICT(id) DO NOTHING`, botArgs(b)...)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return fmt.Errorf("%w: %q", ErrBotExists, b.ID)
+		}
+		return recordRevision(ctx, tx, b.ID)
+	})
+}
+
+const insertBotSQL = `
+	INSERT INTO bots(id, name, type, token, channel_id, invite_link,
+	                 welcome_img_key, welcome_msg, button_text, not_sub_msg,
+	                 success_msg, enabled, stale_update_minutes, tags, api_endpoint,
+	                 proxy, updated_at)
+	VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,NOW())`
+
+func botArgs(b Bot) []interface{} {
+	return []interface{}{
 		b.ID, b.Name, b.Type, b.Token, b.ChannelID, b.InviteLink,
 		b.WelcomeImgKey, b.WelcomeMsg, b.ButtonText, b.NotSubMsg,
-		b.SuccessMsg, b.Enabled,
-	)
+		b.SuccessMsg, b.Enabled, b.StaleUpdateMinutes, b.Tags, b.APIEndpoint,
+		b.Proxy,
+	}
+}
+
+func (d *DB) upsertBot(ctx context.Context, b Bot, version time.Time) error {
+	if b.Tags == nil {
+		b.Tags = []string{}
+	}
+	return pgx.BeginFunc(ctx, d.Pool, func(tx pgx.Tx) error {
+		if !version.IsZero() {
+			var current time.Time
+			err := tx.QueryRow(ctx,
+				`SELECT updated_at FROM bots WHERE id=$1 FOR UPDATE`, b.ID).Scan(&current)
+			if err == pgx.ErrNoRows {
+				return fmt.Errorf("bot %q %w", b.ID, ErrNotFound)
+			}
+			if err != nil {
+				return err
+			}
+			if !current.Equal(version) {
+				return ErrStale
+			}
+		}
+		_, err := tx.Exec(ctx, insertBotSQL+`
+			ON CONFLICT(id) DO UPDATE SET
+			    name=EXCLUDED.name, type=EXCLUDED.type, token=EXCLUDED.token,
+			    channel_id=EXCLUDED.channel_id, invite_link=EXCLUDED.invite_link,
+			    welcome_img_key=EXCLUDED.welcome_img_key, welcome_msg=EXCLUDED.welcome_msg,
+			    button_text=EXCLUDED.button_text, not_sub_msg=EXCLUDED.not_sub_msg,
+			    success_msg=EXCLUDED.success_msg, enabled=EXCLUDED.enabled,
+			    stale_update_minutes=EXCLUDED.stale_update_minutes, tags=EXCLUDED.tags,
+			    api_endpoint=EXCLUDED.api_endpoint, proxy=EXCLUDED.proxy, updated_at=NOW()`,
+			botArgs(b)...,
+		)
+		if err != nil {
+			return err
+		}
+		if err := dropForeignOffset(ctx, tx, b); err != nil {
+			return err
+		}
+		return recordRevision(ctx, tx, b.ID)
+	})
+}
+
+// dropForeignOffset deletes the update offset of b if it was recorded for a
+// different Telegram bot than b's token. Offsets without a recorded ID are
+// kept.
+func dropForeignOffset(ctx context.Context, tx pgx.Tx, b Bot) error {
+	if b.TokenBotID == "" {
+		return nil
+	}
+	_, err := tx.Exec(ctx, `
+		DELETE FROM bot_update_offsets
+		WHERE bot_id=$1 AND token_bot_id NOT IN ('', $2)`, b.ID, b.TokenBotID)
 	return err
 }
 
@@ -112,15 +233,31 @@ func (d *DB) DeleteBot(ctx context.Context, id string) error {
 		return err
 	}
 	if tag.RowsAffected() == 0 {
-		return fmt.Errorf("bot %q not found", id)
+		return fmt.Errorf("bot %q %w", id, ErrNotFound)
 	}
 	return nil
 }
 
+func (d *DB) SetBotEnabled(ctx context.Context, id string, enabled bool) error {
+	return d.updateBot(ctx, id, `UPDATE bots SET enabled=$2, updated_at=NOW() WHERE id=$1`, enabled)
+}
+
 func (d *DB) UpdateWelcomeImg(ctx context.Context, botID, key string) error {
-	_, err := d.Pool.Exec(ctx,
-		`UPDATE bots SET welcome_img_key=$2, updated_at=NOW() WHERE id=$1`, botID, key)
-	return err
+	return d.updateBot(ctx, botID, `UPDATE bots SET welcome_img_key=$2, updated_at=NOW() WHERE id=$1`, key)
+}
+
+// updateBot runs a single-column UPDATE of bot id and records a revision.
+func (d *DB) updateBot(ctx context.Context, id, sql string, value interface{}) error {
+	return pgx.BeginFunc(ctx, d.Pool, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, sql, id, value)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return fmt.Errorf("bot %q %w", id, ErrNotFound)
+		}
+		return recordRevision(ctx, tx, id)
+	})
 }
 
 // --- Assets ---
@@ -167,3 +304,15 @@ func (d *DB) DeleteAsset(ctx context.Context, botID, minioKey string) error {
 	}
 	return nil
 }
+
+// ReplaceBotToken swaps the stored token for an equivalent re-encrypted one.
+// It is a no-op if the token changed in the meantime, and it leaves
+// updated_at alone since the bot's config is unchanged.
+func (d *DB) ReplaceBotToken(ctx context.Context, id, old, token string) (bool, error) {
+	tag, err := d.Pool.Exec(ctx,
+		`UPDATE bots SET token=$3 WHERE id=$1 AND token=$2`, id, old, token)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// BotChat is a chat a bot has seen. Status is the bot's member status from
+// the last my_chat_member update ("" if only known from a forward);
+// ForwardMessageID is the private message in which a post from the chat was
+// last forwarded to the bot.
+type BotChat struct {
+	BotID            string    `json:"bot_id"`
+	ChatID           int64     `json:"chat_id"`
+	Type             string    `json:"type"`
+	Title            string    `json:"title"`
+	Username         string    `json:"username"`
+	Status           string    `json:"status"`
+	ForwardMessageID int       `json:"forward_message_id,omitempty"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// IsAdmin reports whether the bot is an administrator of the chat.
+func (c BotChat) IsAdmin() bool {
+	return c.Status == "administrator" || c.Status == "creator"
+}
+
+const botChatColumns = `bot_id, chat_id, type, title, username, status, forward_message_id, updated_at`
+
+func scanBotChat(row pgx.Row) (BotChat, error) {
+	var c BotChat
+	err := row.Scan(&c.BotID, &c.ChatID, &c.Type, &c.Title, &c.Username,
+		&c.Status, &c.ForwardMessageID, &c.UpdatedAt)
+	return c, err
+}
+
+// UpsertBotChat records what an update said about a chat. Empty Status and
+// zero ForwardMessageID keep the stored values.
+func (d *DB) UpsertBotChat(ctx context.Context, c BotChat) error {
+	_, err := d.Pool.Exec(ctx, `
+		INSERT INTO bot_chats(bot_id, chat_id, type, title, username, status, forward_message_id)
+		VALUES($1,$2,$3,$4,$5,$6,$7)
+		ON CONFLICT(bot_id, chat_id) DO UPDATE SET
+		    type=EXCLUDED.type, title=EXCLUDED.title, username=EXCLUDED.username,
+		    status=CASE WHEN EXCLUDED.status='' THEN bot_chats.status ELSE EXCLUDED.status END,
+		    forward_message_id=CASE WHEN EXCLUDED.forward_message_id=0
+		        THEN bot_chats.forward_message_id ELSE EXCLUDED.forward_message_id END,
+		    updated_at=NOW()`,
+		c.BotID, c.ChatID, c.Type, c.Title, c.Username, c.Status, c.ForwardMessageID,
+	)
+	return err
+}
+
+// GetBotChats lists the chats of a bot, most recently seen first.
+func (d *DB) GetBotChats(ctx context.Context, botID string) ([]BotChat, error) {
+	rows, err := d.Pool.Query(ctx, `
+		SELECT `+botChatColumns+`
+		FROM bot_chats WHERE bot_id=$1 ORDER BY updated_at DESC`, botID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chats []BotChat
+	for rows.Next() {
+		c, err := scanBotChat(rows)
+		if err != nil {
+			return nil, err
+		}
+		chats = append(chats, c)
+	}
+	return chats, rows.Err()
+}
+
+// GetForwardedChat returns the chat whose post was forwarded to the bot in
+// private message messageID.
+func (d *DB) GetForwardedChat(ctx context.Context, botID string, messageID int) (BotChat, error) {
+	c, err := scanBotChat(d.Pool.QueryRow(ctx, `
+		SELECT `+botChatColumns+`
+		FROM bot_chats WHERE bot_id=$1 AND forward_message_id=$2`, botID, messageID))
+	if err == pgx.ErrNoRows {
+		return c, fmt.Errorf("no forwarded message %d for bot %q", messageID, botID)
+	}
+	return c, err
+}
@@ -3,9 +3,6 @@ package db
 import (
 	"context"
 	"fmt"
-	"io/fs"
-	"sort"
-	"strings"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -25,34 +22,6 @@ func New(ctx context.Context, dsn string) (*DB, error) {
 	return &DB{Pool: pool}, nil
 }
 
-// RunMigrations applies all *.sql files from migrationsFS in alphabetical order.
-// migrationsFS should be an fs.FS rooted at the directory containing *.sql files.
-func (d *DB) RunMigrations(ctx context.Context, migrationsFS fs.FS) error {
-	entries, err := fs.ReadDir(migrationsFS, ".")
-	if err != nil {
-		return fmt.Errorf("read migrations: %w", err)
-	}
-
-	var files []string
-	for _, e := range entries {
-		if !e.IsDir() && strings.HasSuffix(e.Name(), ".sql") {
-			files = append(files, e.Name())
-		}
-	}
-	sort.Strings(files)
-
-	for _, name := range files {
-		data, err := fs.ReadFile(migrationsFS, name)
-		if err != nil {
-			return fmt.Errorf("read migration %s: %w", name, err)
-		}
-		if _, err := d.Pool.Exec(ctx, string(data)); err != nil {
-			return fmt.Errorf("apply migration %s: %w", name, err)
-		}
-	}
-	return nil
-}
-
 func (d *DB) GetSetting(ctx context.Context, key string) (string, error) {
 	var val string
 	err := d.Pool.QueryRow(ctx,
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Lease records which replica currently runs a bot.
+type Lease struct {
+	BotID     string    `json:"bot_id"`
+	Owner     string    `json:"owner"`
+	OwnerURL  string    `json:"owner_url"`
+	Status    string    `json:"status"`
+	StatusMsg string    `json:"status_msg"`
+	ExpiresAt time.Time `json:"expires_at"`
+	RenewedAt time.Time `json:"renewed_at"`
+	// Alive reports whether the lease had not expired when it was read. It
+	// is computed by the database clock, which also sets ExpiresAt, so
+	// replicas with skewed clocks agree on it.
+	Alive bool `json:"alive"`
+}
+
+const leaseColumns = `bot_id, owner, owner_url, status, status_msg, expires_at, renewed_at,
+		       expires_at > NOW()`
+
+func scanLease(row pgx.Row) (Lease, error) {
+	var l Lease
+	err := row.Scan(&l.BotID, &l.Owner, &l.OwnerURL, &l.Status, &l.StatusMsg,
+		&l.ExpiresAt, &l.RenewedAt, &l.Alive)
+	return l, err
+}
+
+// AcquireLease takes the lease for botID if it is free, expired or already
+// held by owner. It reports whether owner holds the lease afterwards.
+func (d *DB) AcquireLease(ctx context.Context, botID, owner, ownerURL string, ttl time.Duration) (bool, error) {
+	var got string
+	err := d.Pool.QueryRow(ctx, `
+		INSERT INTO bot_leases(bot_id, owner, owner_url, expires_at, renewed_at)
+		VALUES ($1,$2,$3,NOW()+make_interval(secs => $4),NOW())
+		ON CONFLICT(bot_id) DO UPDATE SET
+		    owner=EXCLUDED.owner, owner_url=EXCLUDED.owner_url,
+		    status='', status_msg='',
+		    expires_at=EXCLUDED.expires_at, renewed_at=NOW()
+		WHERE bot_leases.owner=EXCLUDED.owner OR bot_leases.expires_at < NOW()
+		RETURNING owner`,
+		botID, owner, ownerURL, ttl.Seconds(),
+	).Scan(&got)
+	if err == pgx.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// RenewLease extends a lease held by owner and stores the current runner
+// status. It reports false if the lease has been lost to another replica.
+func (d *DB) RenewLease(ctx context.Context, botID, owner string, ttl time.Duration, status, statusMsg string) (bool, error) {
+	tag, err := d.Pool.Exec(ctx, `
+		UPDATE bot_leases SET
+		    expires_at=NOW()+make_interval(secs => $3), renewed_at=NOW(),
+		    status=$4, status_msg=$5
+		WHERE bot_id=$1 AND owner=$2`,
+		botID, owner, ttl.Seconds(), status, statusMsg,
+	)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// ReleaseLease gives up a lease held by owner so another replica can take
+// over without waiting for it to expire.
+func (d *DB) ReleaseLease(ctx context.Context, botID, owner string) error {
+	_, err := d.Pool.Exec(ctx,
+		`DELETE FROM bot_leases WHERE bot_id=$1 AND owner=$2`, botID, owner)
+	return err
+}
+
+// GetLease returns the current lease for a bot; ok is false if there is none.
+func (d *DB) GetLease(ctx context.Context, botID string) (l Lease, ok bool, err error) {
+	l, err = scanLease(d.Pool.QueryRow(ctx, `
+		SELECT `+leaseColumns+`
+		FROM bot_leases WHERE bot_id=$1`, botID))
+	if err == pgx.ErrNoRows {
+		return l, false, nil
+	}
+	return l, err == nil, err
+}
+
+// GetLeases returns all leases, including expired ones.
+func (d *DB) GetLeases(ctx context.Context) ([]Lease, error) {
+	rows, err := d.Pool.Query(ctx, `
+		SELECT `+leaseColumns+`
+		FROM bot_leases`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var leases []Lease
+	for rows.Next() {
+		l, err := scanLease(rows)
+		if err != nil {
+			return nil, err
+		}
+		leases = append(leases, l)
+	}
+	return leases, rows.Err()
+}
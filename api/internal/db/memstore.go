@@ -0,0 +1,788 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// MemStore is an in-memory Store for tests. It mirrors the PostgreSQL
+// implementation, including foreign keys (rows of a deleted bot go with it)
+// and revision recording, but nothing survives the process.
+type MemStore struct {
+	mu        sync.Mutex
+	bots      map[string]Bot
+	assets    []Asset
+	settings  map[string]string
+	users     map[string]User
+	sessions  map[string]Session
+	templates map[string]Template
+	revisions map[string][]Revision
+	audit     []AuditEntry
+	leases    map[string]Lease
+	offsets   map[string]memOffset
+	outbox    []OutboxItem
+	chats     map[string]map[int64]BotChat
+	seq       int64 // shared ID sequence for assets, audit entries and outbox items
+}
+
+// NewMemStore returns an empty MemStore.
+func NewMemStore() *MemStore {
+	return &MemStore{
+		bots:      make(map[string]Bot),
+		settings:  make(map[string]string),
+		users:     make(map[string]User),
+		sessions:  make(map[string]Session),
+		templates: make(map[string]Template),
+		revisions: make(map[string][]Revision),
+		leases:    make(map[string]Lease),
+		offsets:   make(map[string]memOffset),
+		chats:     make(map[string]map[int64]BotChat),
+	}
+}
+
+// now is truncated like PostgreSQL timestamps, so versions round-trip
+// through ETags.
+func now() time.Time { return time.Now().Truncate(time.Microsecond) }
+
+func (m *MemStore) nextID() int64 {
+	m.seq++
+	return m.seq
+}
+
+func (m *MemStore) botExists(id string) error {
+	if _, ok := m.bots[id]; !ok {
+		return fmt.Errorf("bot %q %w", id, ErrNotFound)
+	}
+	return nil
+}
+
+func copyBot(b Bot) Bot {
+	b.Tags = append([]string{}, b.Tags...)
+	return b
+}
+
+// --- Bots ---
+
+func (m *MemStore) GetAllBots(ctx context.Context) ([]Bot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var bots []Bot
+	for _, b := range m.bots {
+		bots = append(bots, copyBot(b))
+	}
+	sort.Slice(bots, func(i, j int) bool {
+		if !bots[i].CreatedAt.Equal(bots[j].CreatedAt) {
+			return bots[i].CreatedAt.Before(bots[j].CreatedAt)
+		}
+		return bots[i].ID < bots[j].ID
+	})
+	return bots, nil
+}
+
+func (m *MemStore) GetBot(ctx context.Context, id string) (Bot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.bots[id]
+	if !ok {
+		return Bot{}, fmt.Errorf("bot %q %w", id, ErrNotFound)
+	}
+	return copyBot(b), nil
+}
+
+func (m *MemStore) UpsertBot(ctx context.Context, b Bot) error {
+	return m.upsertBot(ctx, b, time.Time{})
+}
+
+func (m *MemStore) CreateBot(ctx context.Context, b Bot) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.bots[b.ID]; exists {
+		return fmt.Errorf("%w: %q", ErrBotExists, b.ID)
+	}
+	b = copyBot(b)
+	b.UpdatedAt = now()
+	b.CreatedAt = b.UpdatedAt
+	m.bots[b.ID] = b
+	return m.recordRevision(ctx, b.ID)
+}
+
+func (m *MemStore) UpdateBotIfUnchanged(ctx context.Context, b Bot, version time.Time) error {
+	return m.upsertBot(ctx, b, version)
+}
+
+func (m *MemStore) upsertBot(ctx context.Context, b Bot, version time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cur, exists := m.bots[b.ID]
+	if !version.IsZero() {
+		if !exists {
+			return fmt.Errorf("bot %q %w", b.ID, ErrNotFound)
+		}
+		if !cur.UpdatedAt.Equal(version) {
+			return ErrStale
+		}
+	}
+	b = copyBot(b)
+	b.UpdatedAt = now()
+	b.CreatedAt = b.UpdatedAt
+	if exists {
+		b.CreatedAt = cur.CreatedAt
+	}
+	m.bots[b.ID] = b
+	// Like dropForeignOffset.
+	if off := m.offsets[b.ID]; b.TokenBotID != "" && off.tokenBotID != "" && off.tokenBotID != b.TokenBotID {
+		delete(m.offsets, b.ID)
+	}
+	return m.recordRevision(ctx, b.ID)
+}
+
+func (m *MemStore) DeleteBot(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.botExists(id); err != nil {
+		return err
+	}
+	delete(m.bots, id)
+	// ON DELETE CASCADE
+	m.assets = slices.DeleteFunc(m.assets, func(a Asset) bool { return a.BotID == id })
+	m.outbox = slices.DeleteFunc(m.outbox, func(it OutboxItem) bool { return it.BotID == id })
+	delete(m.revisions, id)
+	delete(m.leases, id)
+	delete(m.offsets, id)
+	delete(m.chats, id)
+	return nil
+}
+
+func (m *MemStore) SetBotEnabled(ctx context.Context, id string, enabled bool) error {
+	return m.updateBot(ctx, id, func(b *Bot) { b.Enabled = enabled })
+}
+
+func (m *MemStore) UpdateWelcomeImg(ctx context.Context, botID, key string) error {
+	return m.updateBot(ctx, botID, func(b *Bot) { b.WelcomeImgKey = key })
+}
+
+func (m *MemStore) updateBot(ctx context.Context, id string, change func(*Bot)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.bots[id]
+	if !ok {
+		return fmt.Errorf("bot %q %w", id, ErrNotFound)
+	}
+	change(&b)
+	b.UpdatedAt = now()
+	m.bots[id] = b
+	return m.recordRevision(ctx, id)
+}
+
+func (m *MemStore) ReplaceBotToken(ctx context.Context, id, old, token string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.bots[id]
+	if !ok || b.Token != old {
+		return false, nil
+	}
+	b.Token = token
+	m.bots[id] = b
+	return true, nil
+}
+
+// recordRevision is the counterpart of the PostgreSQL recordRevision; the
+// snapshot goes through JSON like the jsonb column does.
+func (m *MemStore) recordRevision(ctx context.Context, id string) error {
+	b := m.bots[id]
+	b.Token = ""
+	b.Proxy = RedactProxy(b.Proxy)
+	data, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	var snapshot Bot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return err
+	}
+	revs := m.revisions[id]
+	m.revisions[id] = append(revs, Revision{
+		BotID:     id,
+		Rev:       len(revs) + 1,
+		Author:    ActorFrom(ctx),
+		Snapshot:  snapshot,
+		CreatedAt: now(),
+	})
+	return nil
+}
+
+// --- Assets ---
+
+func (m *MemStore) GetAssets(ctx context.Context, botID string) ([]Asset, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var assets []Asset
+	for _, a := range m.assets {
+		if a.BotID == botID {
+			assets = append(assets, a)
+		}
+	}
+	return assets, nil
+}
+
+func (m *MemStore) InsertAsset(ctx context.Context, a Asset) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.botExists(a.BotID); err != nil {
+		return err
+	}
+	a.ID = int(m.nextID())
+	a.CreatedAt = now()
+	m.assets = append(m.assets, a)
+	return nil
+}
+
+func (m *MemStore) DeleteAsset(ctx context.Context, botID, minioKey string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := len(m.assets)
+	m.assets = slices.DeleteFunc(m.assets, func(a Asset) bool { return a.BotID == botID && a.MinioKey == minioKey })
+	if len(m.assets) == n {
+		return fmt.Errorf("asset %q not found", minioKey)
+	}
+	return nil
+}
+
+// --- Settings ---
+
+// GetSetting returns pgx.ErrNoRows for an unknown key, like *DB.
+func (m *MemStore) GetSetting(ctx context.Context, key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.settings[key]
+	if !ok {
+		return "", pgx.ErrNoRows
+	}
+	return v, nil
+}
+
+func (m *MemStore) SetSetting(ctx context.Context, key, value string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.settings[key] = value
+	return nil
+}
+
+// --- Users ---
+
+func (m *MemStore) GetUsers(ctx context.Context) ([]User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var users []User
+	for _, u := range m.users {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	return users, nil
+}
+
+func (m *MemStore) GetUser(ctx context.Context, username string) (User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[username]
+	if !ok {
+		return User{}, fmt.Errorf("user %q not found", username)
+	}
+	return u, nil
+}
+
+func (m *MemStore) CreateUser(ctx context.Context, u User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.users[u.Username]; ok {
+		return fmt.Errorf("%w: %q", ErrUserExists, u.Username)
+	}
+	u.CreatedAt = now()
+	u.UpdatedAt = u.CreatedAt
+	m.users[u.Username] = u
+	return nil
+}
+
+func (m *MemStore) UpdateUser(ctx context.Context, u User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cur, ok := m.users[u.Username]
+	if !ok {
+		return fmt.Errorf("user %q not found", u.Username)
+	}
+	if u.Role != RoleOwner && m.lastOwner(u.Username) {
+		return ErrLastOwner
+	}
+	cur.Role, cur.PasswordHash, cur.UpdatedAt = u.Role, u.PasswordHash, now()
+	m.users[u.Username] = cur
+	return nil
+}
+
+func (m *MemStore) DeleteUser(ctx context.Context, username string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.users[username]; !ok {
+		return fmt.Errorf("user %q not found", username)
+	}
+	if m.lastOwner(username) {
+		return ErrLastOwner
+	}
+	delete(m.users, username)
+	for id, s := range m.sessions {
+		if s.Username == username {
+			delete(m.sessions, id)
+		}
+	}
+	return nil
+}
+
+// lastOwner reports whether username is the only owner. Callers hold m.mu.
+func (m *MemStore) lastOwner(username string) bool {
+	for _, u := range m.users {
+		if u.Role == RoleOwner && u.Username != username {
+			return false
+		}
+	}
+	return m.users[username].Role == RoleOwner
+}
+
+// --- Sessions ---
+
+func (m *MemStore) CreateSession(ctx context.Context, s Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.users[s.Username]; !ok {
+		return fmt.Errorf("user %q not found", s.Username)
+	}
+	s.CreatedAt = now()
+	s.LastSeenAt = s.CreatedAt
+	m.sessions[s.ID] = s
+	return nil
+}
+
+func (m *MemStore) GetSessionByToken(ctx context.Context, tokenHash string) (Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, s := range m.sessions {
+		if s.TokenHash == tokenHash && s.ExpiresAt.After(time.Now()) {
+			return s, nil
+		}
+	}
+	return Session{}, fmt.Errorf("session not found")
+}
+
+func (m *MemStore) GetSessions(ctx context.Context, username string) ([]Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var sessions []Session
+	for _, s := range m.sessions {
+		if s.Username == username && s.ExpiresAt.After(time.Now()) {
+			sessions = append(sessions, s)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].LastSeenAt.Equal(sessions[j].LastSeenAt) {
+			return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+		}
+		return sessions[i].ID < sessions[j].ID
+	})
+	return sessions, nil
+}
+
+func (m *MemStore) TouchSession(ctx context.Context, id string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.sessions[id]; ok {
+		s.LastSeenAt, s.ExpiresAt = now(), expiresAt
+		m.sessions[id] = s
+	}
+	return nil
+}
+
+func (m *MemStore) DeleteSession(ctx context.Context, username, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.sessions[id]; !ok || s.Username != username {
+		return fmt.Errorf("session %q not found", id)
+	}
+	delete(m.sessions, id)
+	return nil
+}
+
+func (m *MemStore) DeleteSessions(ctx context.Context, username, keep string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for id, s := range m.sessions {
+		if s.Username == username && id != keep {
+			delete(m.sessions, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *MemStore) DeleteExpiredSessions(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, s := range m.sessions {
+		if !s.ExpiresAt.After(time.Now()) {
+			delete(m.sessions, id)
+		}
+	}
+	return nil
+}
+
+// --- Templates ---
+
+func copyTemplate(t Template) Template {
+	t.Tags = append([]string{}, t.Tags...)
+	t.Assets = append([]TemplateAsset{}, t.Assets...)
+	return t
+}
+
+func (m *MemStore) GetTemplates(ctx context.Context) ([]Template, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var templates []Template
+	for _, t := range m.templates {
+		templates = append(templates, copyTemplate(t))
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].ID < templates[j].ID })
+	return templates, nil
+}
+
+func (m *MemStore) GetTemplate(ctx context.Context, id string) (Template, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.templates[id]
+	if !ok {
+		return Template{}, fmt.Errorf("template %q %w", id, ErrNotFound)
+	}
+	return copyTemplate(t), nil
+}
+
+func (m *MemStore) UpsertTemplate(ctx context.Context, t Template) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t = copyTemplate(t)
+	t.UpdatedAt = now()
+	t.CreatedAt = t.UpdatedAt
+	if cur, ok := m.templates[t.ID]; ok {
+		t.CreatedAt = cur.CreatedAt
+	}
+	m.templates[t.ID] = t
+	return nil
+}
+
+func (m *MemStore) DeleteTemplate(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.templates[id]; !ok {
+		return fmt.Errorf("template %q %w", id, ErrNotFound)
+	}
+	delete(m.templates, id)
+	return nil
+}
+
+// --- Revisions and audit log ---
+
+func (m *MemStore) GetRevisions(ctx context.Context, botID string) ([]Revision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var revs []Revision
+	for i := len(m.revisions[botID]) - 1; i >= 0; i-- {
+		revs = append(revs, m.revisions[botID][i])
+	}
+	return revs, nil
+}
+
+func (m *MemStore) GetRevision(ctx context.Context, botID string, rev int) (Revision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	revs := m.revisions[botID]
+	if rev == 0 && len(revs) > 0 {
+		return revs[len(revs)-1], nil
+	}
+	if rev > 0 && rev <= len(revs) {
+		return revs[rev-1], nil
+	}
+	return Revision{}, fmt.Errorf("revision %d of bot %q not found", rev, botID)
+}
+
+func (m *MemStore) RecordAudit(ctx context.Context, action, target, detail string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.audit = append(m.audit, AuditEntry{
+		ID:        m.nextID(),
+		Actor:     ActorFrom(ctx),
+		Action:    action,
+		Target:    target,
+		Detail:    detail,
+		CreatedAt: now(),
+	})
+	return nil
+}
+
+func (m *MemStore) GetAuditLog(ctx context.Context, limit int) ([]AuditEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var entries []AuditEntry
+	for i := len(m.audit) - 1; i >= 0 && len(entries) < limit; i-- {
+		entries = append(entries, m.audit[i])
+	}
+	return entries, nil
+}
+
+// --- Leases ---
+
+func (m *MemStore) AcquireLease(ctx context.Context, botID, owner, ownerURL string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.botExists(botID); err != nil {
+		return false, err
+	}
+	if l, ok := m.leases[botID]; ok && l.Owner != owner && now().Before(l.ExpiresAt) {
+		return false, nil
+	}
+	t := now()
+	m.leases[botID] = Lease{BotID: botID, Owner: owner, OwnerURL: ownerURL, ExpiresAt: t.Add(ttl), RenewedAt: t}
+	return true, nil
+}
+
+func (m *MemStore) RenewLease(ctx context.Context, botID, owner string, ttl time.Duration, status, statusMsg string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l, ok := m.leases[botID]
+	if !ok || l.Owner != owner {
+		return false, nil
+	}
+	t := now()
+	l.ExpiresAt, l.RenewedAt = t.Add(ttl), t
+	l.Status, l.StatusMsg = status, statusMsg
+	m.leases[botID] = l
+	return true, nil
+}
+
+func (m *MemStore) ReleaseLease(ctx context.Context, botID, owner string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if l, ok := m.leases[botID]; ok && l.Owner == owner {
+		delete(m.leases, botID)
+	}
+	return nil
+}
+
+func (m *MemStore) GetLease(ctx context.Context, botID string) (Lease, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l, ok := m.leases[botID]
+	l.Alive = ok && now().Before(l.ExpiresAt)
+	return l, ok, nil
+}
+
+func (m *MemStore) GetLeases(ctx context.Context) ([]Lease, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var leases []Lease
+	t := now()
+	for _, l := range m.leases {
+		l.Alive = t.Before(l.ExpiresAt)
+		leases = append(leases, l)
+	}
+	return leases, nil
+}
+
+// --- Update offsets ---
+
+type memOffset struct {
+	tokenBotID string
+	updateID   int
+}
+
+func (m *MemStore) GetUpdateOffset(ctx context.Context, botID string) (int, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	off, ok := m.offsets[botID]
+	return off.updateID, ok, nil
+}
+
+func (m *MemStore) SetUpdateOffset(ctx context.Context, botID, tokenBotID string, updateID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.botExists(botID); err != nil {
+		return err
+	}
+	m.offsets[botID] = memOffset{tokenBotID, updateID}
+	return nil
+}
+
+// --- Outbox ---
+
+func (m *MemStore) EnqueueOutbox(ctx context.Context, items ...OutboxItem) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, it := range items {
+		if err := m.botExists(it.BotID); err != nil {
+			return err
+		}
+	}
+	t := now()
+	for _, it := range items {
+		it.ID = m.nextID()
+		it.Status = OutboxPending
+		it.Attempts = 0
+		it.LastError = ""
+		it.NextAttemptAt = t
+		it.CreatedAt = t
+		m.outbox = append(m.outbox, it)
+	}
+	return nil
+}
+
+func (m *MemStore) DueOutbox(ctx context.Context, botID string, limit int) ([]OutboxItem, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// Only the oldest pending item of each chat is eligible, which keeps
+	// per-chat order (the DISTINCT ON query of *DB).
+	heads := make(map[int64]bool)
+	var due []OutboxItem
+	t := time.Now()
+	for _, it := range m.outbox {
+		if it.BotID != botID || it.Status != OutboxPending || heads[it.ChatID] {
+			continue
+		}
+		heads[it.ChatID] = true
+		if !it.NextAttemptAt.After(t) && len(due) < limit {
+			due = append(due, it)
+		}
+	}
+	return due, nil
+}
+
+func (m *MemStore) ListOutbox(ctx context.Context, botID string, status OutboxStatus) ([]OutboxItem, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var items []OutboxItem
+	for _, it := range m.outbox {
+		if it.BotID == botID && it.Status == status {
+			items = append(items, it)
+		}
+	}
+	return items, nil
+}
+
+func (m *MemStore) CompleteOutboxItem(ctx context.Context, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.outbox = slices.DeleteFunc(m.outbox, func(it OutboxItem) bool { return it.ID == id })
+	return nil
+}
+
+// updateOutbox applies change to the item with the given id if match holds
+// and reports whether it did.
+func (m *MemStore) updateOutbox(id int64, match func(OutboxItem) bool, change func(*OutboxItem)) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.outbox {
+		if m.outbox[i].ID == id && match(m.outbox[i]) {
+			change(&m.outbox[i])
+			return true
+		}
+	}
+	return false
+}
+
+func anyItem(OutboxItem) bool { return true }
+
+func (m *MemStore) RetryOutboxItem(ctx context.Context, id int64, attempts int, next time.Time, lastErr string) error {
+	m.updateOutbox(id, anyItem, func(it *OutboxItem) {
+		it.Attempts, it.NextAttemptAt, it.LastError = attempts, next, lastErr
+	})
+	return nil
+}
+
+func (m *MemStore) DeadLetterOutboxItem(ctx context.Context, id int64, attempts int, lastErr string) error {
+	m.updateOutbox(id, anyItem, func(it *OutboxItem) {
+		it.Status, it.Attempts, it.LastError = OutboxDead, attempts, lastErr
+	})
+	return nil
+}
+
+func (m *MemStore) RequeueOutboxItem(ctx context.Context, botID string, id int64) error {
+	dead := func(it OutboxItem) bool { return it.BotID == botID && it.Status == OutboxDead }
+	if !m.updateOutbox(id, dead, func(it *OutboxItem) {
+		it.Status, it.Attempts, it.NextAttemptAt = OutboxPending, 0, now()
+	}) {
+		return fmt.Errorf("dead outbox item %d not found", id)
+	}
+	return nil
+}
+
+func (m *MemStore) DeleteOutboxItem(ctx context.Context, botID string, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := len(m.outbox)
+	m.outbox = slices.DeleteFunc(m.outbox, func(it OutboxItem) bool {
+		return it.ID == id && it.BotID == botID && it.Status == OutboxDead
+	})
+	if len(m.outbox) == n {
+		return fmt.Errorf("dead outbox item %d not found", id)
+	}
+	return nil
+}
+
+// --- Chats ---
+
+func (m *MemStore) UpsertBotChat(ctx context.Context, c BotChat) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.botExists(c.BotID); err != nil {
+		return err
+	}
+	chats := m.chats[c.BotID]
+	if chats == nil {
+		chats = make(map[int64]BotChat)
+		m.chats[c.BotID] = chats
+	}
+	if old, ok := chats[c.ChatID]; ok {
+		if c.Status == "" {
+			c.Status = old.Status
+		}
+		if c.ForwardMessageID == 0 {
+			c.ForwardMessageID = old.ForwardMessageID
+		}
+	}
+	c.UpdatedAt = now()
+	chats[c.ChatID] = c
+	return nil
+}
+
+func (m *MemStore) GetBotChats(ctx context.Context, botID string) ([]BotChat, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var chats []BotChat
+	for _, c := range m.chats[botID] {
+		chats = append(chats, c)
+	}
+	sort.Slice(chats, func(i, j int) bool { return chats[i].UpdatedAt.After(chats[j].UpdatedAt) })
+	return chats, nil
+}
+
+func (m *MemStore) GetForwardedChat(ctx context.Context, botID string, messageID int) (BotChat, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range m.chats[botID] {
+		if c.ForwardMessageID == messageID {
+			return c, nil
+		}
+	}
+	return BotChat{}, fmt.Errorf("no forwarded message %d for bot %q", messageID, botID)
+}
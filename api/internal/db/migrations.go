@@ -0,0 +1,216 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// migrationLockKey is the pg_advisory_lock key that serialises migrations
+// between replicas starting at the same time.
+const migrationLockKey int64 = 0x626f746d6772 // "botmgr"
+
+// Migration states reported by MigrationStatus.
+const (
+	MigrationApplied  = "applied"
+	MigrationPending  = "pending"
+	MigrationModified = "modified" // file changed after it was applied
+	MigrationMissing  = "missing"  // recorded as applied but the file is gone
+)
+
+// MigrationState describes one migration file or schema_migrations row.
+type MigrationState struct {
+	Version   string     `json:"version"`
+	State     string     `json:"state"`
+	Checksum  string     `json:"checksum"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+type migrationFile struct {
+	version  string
+	sql      string
+	checksum string
+}
+
+type appliedMigration struct {
+	checksum  string
+	appliedAt time.Time
+}
+
+// readMigrations returns all *.sql files from migrationsFS in alphabetical
+// order; the file name is the migration version.
+func readMigrations(migrationsFS fs.FS) ([]migrationFile, error) {
+	entries, err := fs.ReadDir(migrationsFS, ".")
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+
+	var files []migrationFile
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".sql") {
+			continue
+		}
+		data, err := fs.ReadFile(migrationsFS, e.Name())
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", e.Name(), err)
+		}
+		sum := sha256.Sum256(data)
+		files = append(files, migrationFile{
+			version:  e.Name(),
+			sql:      string(data),
+			checksum: hex.EncodeToString(sum[:]),
+		})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].version < files[j].version })
+	return files, nil
+}
+
+func ensureMigrationsTable(ctx context.Context, conn *pgxpool.Conn) error {
+	_, err := conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+		    version    TEXT PRIMARY KEY,
+		    checksum   TEXT NOT NULL,
+		    applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`)
+	return err
+}
+
+func appliedMigrations(ctx context.Context, conn *pgxpool.Conn) (map[string]appliedMigration, error) {
+	rows, err := conn.Query(ctx, `SELECT version, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[string]appliedMigration)
+	for rows.Next() {
+		var version string
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = a
+	}
+	return applied, rows.Err()
+}
+
+// RunMigrations applies the *.sql files from migrationsFS that are not yet
+// recorded in schema_migrations, in alphabetical order, each in its own
+// transaction. migrationsFS should be an fs.FS rooted at the directory
+// containing *.sql files.
+//
+// An advisory lock keeps concurrently starting replicas from racing, and a
+// recorded migration whose file has since been edited is an error.
+//
+// Databases created before schema_migrations existed have no records yet;
+// their migrations are all idempotent, so they are simply re-run once and
+// recorded.
+func (d *DB) RunMigrations(ctx context.Context, migrationsFS fs.FS) error {
+	files, err := readMigrations(migrationsFS)
+	if err != nil {
+		return err
+	}
+
+	// Session-level advisory locks belong to a connection, so everything runs
+	// on one connection taken from the pool.
+	conn, err := d.Pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey); err != nil {
+		return fmt.Errorf("lock migrations: %w", err)
+	}
+	defer conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockKey) //nolint:errcheck
+
+	if err := ensureMigrationsTable(ctx, conn); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("load schema_migrations: %w", err)
+	}
+
+	for _, f := range files {
+		if a, ok := applied[f.version]; ok {
+			if a.checksum != f.checksum {
+				return fmt.Errorf("migration %s was modified after it was applied (checksum %s, file %s)",
+					f.version, a.checksum[:12], f.checksum[:12])
+			}
+			continue
+		}
+		if err := applyMigration(ctx, conn, f); err != nil {
+			return fmt.Errorf("apply migration %s: %w", f.version, err)
+		}
+	}
+	return nil
+}
+
+func applyMigration(ctx context.Context, conn *pgxpool.Conn, f migrationFile) error {
+	return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, f.sql); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx,
+			`INSERT INTO schema_migrations(version, checksum) VALUES($1,$2)`, f.version, f.checksum)
+		return err
+	})
+}
+
+// MigrationStatus compares the migration files with schema_migrations without
+// applying anything.
+func (d *DB) MigrationStatus(ctx context.Context, migrationsFS fs.FS) ([]MigrationState, error) {
+	files, err := readMigrations(migrationsFS)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := d.Pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	if err := ensureMigrationsTable(ctx, conn); err != nil {
+		return nil, fmt.Errorf("create schema_migrations: %w", err)
+	}
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return nil, fmt.Errorf("load schema_migrations: %w", err)
+	}
+
+	var out []MigrationState
+	for _, f := range files {
+		st := MigrationState{Version: f.version, State: MigrationPending, Checksum: f.checksum}
+		if a, ok := applied[f.version]; ok {
+			appliedAt := a.appliedAt
+			st.AppliedAt = &appliedAt
+			st.State = MigrationApplied
+			if a.checksum != f.checksum {
+				st.State = MigrationModified
+			}
+			delete(applied, f.version)
+		}
+		out = append(out, st)
+	}
+	for version, a := range applied {
+		appliedAt := a.appliedAt
+		out = append(out, MigrationState{
+			Version:   version,
+			State:     MigrationMissing,
+			Checksum:  a.checksum,
+			AppliedAt: &appliedAt,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
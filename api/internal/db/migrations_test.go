@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestReadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"010_b.sql":      {Data: []byte("SELECT 10;")},
+		"002_a.sql":      {Data: []byte("SELECT 2;")},
+		"README.md":      {Data: []byte("not a migration")},
+		"old/001_x.sql":  {Data: []byte("SELECT 1;")},
+		"003_c.sql.orig": {Data: []byte("SELECT 3;")},
+		"001_create.sql": {Data: []byte("SELECT 1;")},
+	}
+	files, err := readMigrations(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var versions []string
+	for _, f := range files {
+		versions = append(versions, f.version)
+	}
+	if got := strings.Join(versions, ","); got != "001_create.sql,002_a.sql,010_b.sql" {
+		t.Errorf("versions = %s", got)
+	}
+	sum := sha256.Sum256([]byte("SELECT 2;"))
+	if files[1].sql != "SELECT 2;" || files[1].checksum != hex.EncodeToString(sum[:]) {
+		t.Errorf("002_a.sql = %+v", files[1])
+	}
+
+	// The shipped migrations read cleanly and in numeric order.
+	shipped, err := readMigrations(os.DirFS("../../cmd/server/migrations"))
+	if err != nil || len(shipped) == 0 || shipped[0].version != "001_create_bots.sql" {
+		t.Fatalf("shipped migrations = %d, %v", len(shipped), err)
+	}
+}
+
+func TestPostgresMigrationChecks(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	ctx := context.Background()
+	d, err := New(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Pool.Close()
+	shipped := os.DirFS("../../cmd/server/migrations")
+	if err := d.RunMigrations(ctx, shipped); err != nil {
+		t.Fatal(err)
+	}
+	// Running again applies nothing and succeeds.
+	if err := d.RunMigrations(ctx, shipped); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+
+	first, err := fs.ReadFile(shipped, "001_create_bots.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := fstest.MapFS{
+		"001_create_bots.sql": {Data: append(first, "\n-- edited\n"...)},
+		"999_new.sql":         {Data: []byte("SELECT 1;")},
+	}
+	if err := d.RunMigrations(ctx, edited); err == nil || !strings.Contains(err.Error(), "001_create_bots.sql was modified") {
+		t.Errorf("RunMigrations with an edited file = %v", err)
+	}
+
+	states, err := d.MigrationStatus(ctx, edited)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, st := range states {
+		got[st.Version] = st.State
+	}
+	if got["001_create_bots.sql"] != MigrationModified || got["999_new.sql"] != MigrationPending ||
+		got["002_create_bot_assets.sql"] != MigrationMissing {
+		t.Errorf("MigrationStatus = %v", got)
+	}
+}
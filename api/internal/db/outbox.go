@@ -0,0 +1,161 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type OutboxKind string
+
+const (
+	OutboxMessage  OutboxKind = "message"
+	OutboxPhoto    OutboxKind = "photo"
+	OutboxDocument OutboxKind = "document"
+)
+
+type OutboxStatus string
+
+const (
+	OutboxPending OutboxStatus = "pending"
+	OutboxDead    OutboxStatus = "dead"
+)
+
+// OutboxItem is a single queued Telegram send. Text is the message text or the
+// photo caption; FileKey points at the MinIO object for photos and documents.
+type OutboxItem struct {
+	ID            int64           `json:"id"`
+	BotID         string          `json:"bot_id"`
+	ChatID        int64           `json:"chat_id"`
+	Kind          OutboxKind      `json:"kind"`
+	Text          string          `json:"text"`
+	FileKey       string          `json:"file_key"`
+	FileName      string          `json:"file_name"`
+	ReplyMarkup   json.RawMessage `json:"reply_markup,omitempty"`
+	Status        OutboxStatus    `json:"status"`
+	Attempts      int             `json:"attempts"`
+	LastError     string          `json:"last_error"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+const outboxColumns = `id, bot_id, chat_id, kind, text, file_key, file_name, reply_markup,
+		       status, attempts, last_error, next_attempt_at, created_at`
+
+func scanOutboxItems(rows pgx.Rows) ([]OutboxItem, error) {
+	defer rows.Close()
+	var items []OutboxItem
+	for rows.Next() {
+		var it OutboxItem
+		if err := rows.Scan(
+			&it.ID, &it.BotID, &it.ChatID, &it.Kind, &it.Text, &it.FileKey, &it.FileName,
+			&it.ReplyMarkup, &it.Status, &it.Attempts, &it.LastError, &it.NextAttemptAt, &it.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	return items, rows.Err()
+}
+
+// EnqueueOutbox inserts items in a single transaction so that their IDs, and
+// therefore their delivery order within a chat, follow the argument order.
+func (d *DB) EnqueueOutbox(ctx context.Context, items ...OutboxItem) error {
+	tx, err := d.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck
+
+	for _, it := range items {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO bot_outbox(bot_id, chat_id, kind, text, file_key, file_name, reply_markup)
+			VALUES ($1,$2,$3,$4,$5,$6,$7)`,
+			it.BotID, it.ChatID, it.Kind, it.Text, it.FileKey, it.FileName, it.ReplyMarkup,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// DueOutbox returns, for every chat of the bot, the oldest pending item —
+// but only if it is due. A chat whose head item is waiting for a retry is
+// skipped entirely, which is what keeps delivery ordered per chat.
+func (d *DB) DueOutbox(ctx context.Context, botID string, limit int) ([]OutboxItem, error) {
+	rows, err := d.Pool.Query(ctx, `
+		SELECT `+outboxColumns+` FROM (
+		    SELECT DISTINCT ON (chat_id) * FROM bot_outbox
+		    WHERE bot_id=$1 AND status='pending'
+		    ORDER BY chat_id, id
+		) head
+		WHERE next_attempt_at <= NOW()
+		ORDER BY id LIMIT $2`, botID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanOutboxItems(rows)
+}
+
+// ListOutbox returns a bot's items with the given status, oldest first.
+func (d *DB) ListOutbox(ctx context.Context, botID string, status OutboxStatus) ([]OutboxItem, error) {
+	rows, err := d.Pool.Query(ctx, `
+		SELECT `+outboxColumns+` FROM bot_outbox
+		WHERE bot_id=$1 AND status=$2 ORDER BY id`, botID, status)
+	if err != nil {
+		return nil, err
+	}
+	return scanOutboxItems(rows)
+}
+
+// CompleteOutboxItem removes a delivered item.
+func (d *DB) CompleteOutboxItem(ctx context.Context, id int64) error {
+	_, err := d.Pool.Exec(ctx, `DELETE FROM bot_outbox WHERE id=$1`, id)
+	return err
+}
+
+// RetryOutboxItem schedules another delivery attempt.
+func (d *DB) RetryOutboxItem(ctx context.Context, id int64, attempts int, next time.Time, lastErr string) error {
+	_, err := d.Pool.Exec(ctx, `
+		UPDATE bot_outbox SET attempts=$2, next_attempt_at=$3, last_error=$4
+		WHERE id=$1`, id, attempts, next, lastErr)
+	return err
+}
+
+// DeadLetterOutboxItem gives up on an item; it stays visible through the API.
+func (d *DB) DeadLetterOutboxItem(ctx context.Context, id int64, attempts int, lastErr string) error {
+	_, err := d.Pool.Exec(ctx, `
+		UPDATE bot_outbox SET status='dead', attempts=$2, last_error=$3
+		WHERE id=$1`, id, attempts, lastErr)
+	return err
+}
+
+// RequeueOutboxItem moves a dead-lettered item back to the pending queue.
+func (d *DB) RequeueOutboxItem(ctx context.Context, botID string, id int64) error {
+	tag, err := d.Pool.Exec(ctx, `
+		UPDATE bot_outbox SET status='pending', attempts=0, next_attempt_at=NOW()
+		WHERE bot_id=$1 AND id=$2 AND status='dead'`, botID, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("dead outbox item %d not found", id)
+	}
+	return nil
+}
+
+// DeleteOutboxItem discards a dead-lettered item.
+func (d *DB) DeleteOutboxItem(ctx context.Context, botID string, id int64) error {
+	tag, err := d.Pool.Exec(ctx,
+		`DELETE FROM bot_outbox WHERE bot_id=$1 AND id=$2 AND status='dead'`, botID, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("dead outbox item %d not found", id)
+	}
+	return nil
+}
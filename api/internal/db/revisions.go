@@ -0,0 +1,149 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type actorKey struct{}
+
+// WithActor returns a context carrying the name of whoever makes the changes;
+// it is recorded as the author of the bot revisions written under ctx.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor set by WithActor, or "system".
+func ActorFrom(ctx context.Context) string {
+	if a, ok := ctx.Value(actorKey{}).(string); ok && a != "" {
+		return a
+	}
+	return "system"
+}
+
+// Revision is a snapshot of a bot's config after a create or update. The
+// snapshot never contains the token.
+type Revision struct {
+	BotID     string    `json:"bot_id"`
+	Rev       int       `json:"rev"`
+	Author    string    `json:"author"`
+	Snapshot  Bot       `json:"snapshot"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// FieldChange is one differing field between two revisions; From and To are
+// the JSON values.
+type FieldChange struct {
+	Field string          `json:"field"`
+	From  json.RawMessage `json:"from"`
+	To    json.RawMessage `json:"to"`
+}
+
+// rowQuerier is satisfied by both the pool and a transaction.
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// recordRevision snapshots the current row of bot id as its next revision.
+// It must run in the transaction that changed the row, which also holds the
+// row lock that keeps revision numbers from racing.
+func recordRevision(ctx context.Context, q rowQuerier, id string) error {
+	b, err := scanBot(q.QueryRow(ctx, `SELECT `+botColumns+` FROM bots WHERE id=$1`, id))
+	if err != nil {
+		return err
+	}
+	b.Token = ""
+	b.Proxy = RedactProxy(b.Proxy)
+	snapshot, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	var rev int
+	return q.QueryRow(ctx, `
+		INSERT INTO bot_revisions(bot_id, rev, author, snapshot)
+		SELECT $1, COALESCE(MAX(rev), 0) + 1, $2, $3
+		FROM bot_revisions WHERE bot_id=$1
+		RETURNING rev`,
+		id, ActorFrom(ctx), snapshot,
+	).Scan(&rev)
+}
+
+func scanRevision(row pgx.Row) (Revision, error) {
+	var r Revision
+	err := row.Scan(&r.BotID, &r.Rev, &r.Author, &r.Snapshot, &r.CreatedAt)
+	return r, err
+}
+
+// GetRevisions lists the revisions of a bot, newest first.
+func (d *DB) GetRevisions(ctx context.Context, botID string) ([]Revision, error) {
+	rows, err := d.Pool.Query(ctx, `
+		SELECT bot_id, rev, author, snapshot, created_at
+		FROM bot_revisions WHERE bot_id=$1 ORDER BY rev DESC`, botID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revs []Revision
+	for rows.Next() {
+		r, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revs = append(revs, r)
+	}
+	return revs, rows.Err()
+}
+
+// GetRevision returns revision rev of a bot; rev 0 means the latest.
+func (d *DB) GetRevision(ctx context.Context, botID string, rev int) (Revision, error) {
+	r, err := scanRevision(d.Pool.QueryRow(ctx, `
+		SELECT bot_id, rev, author, snapshot, created_at
+		FROM bot_revisions WHERE bot_id=$1 AND ($2 = 0 OR rev=$2)
+		ORDER BY rev DESC LIMIT 1`, botID, rev))
+	if err == pgx.ErrNoRows {
+		return r, fmt.Errorf("revision %d of bot %q not found", rev, botID)
+	}
+	return r, err
+}
+
+// DiffBots lists the config fields that differ between two snapshots.
+// Timestamps are ignored.
+func DiffBots(from, to Bot) ([]FieldChange, error) {
+	a, err := botFields(from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := botFields(to)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []FieldChange{}
+	for field, av := range a {
+		if field == "created_at" || field == "updated_at" {
+			continue
+		}
+		if bv := b[field]; !bytes.Equal(av, bv) {
+			changes = append(changes, FieldChange{Field: field, From: av, To: bv})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes, nil
+}
+
+func botFields(b Bot) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal(data, &fields)
+	return fields, err
+}
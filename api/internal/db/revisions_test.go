@@ -0,0 +1,31 @@
+package db
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiffBots(t *testing.T) {
+	from := Bot{ID: "gate", Name: "Gate", Tags: []string{"prod"}, ChannelID: -1001, UpdatedAt: time.Now()}
+	to := from
+	to.Name, to.Tags, to.WelcomeMsg = "Gate 2", nil, "Hi"
+	to.UpdatedAt = from.UpdatedAt.Add(time.Hour)
+
+	changes, err := DiffBots(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.Field+":"+string(c.From)+"→"+string(c.To))
+	}
+	want := []string{`name:"Gate"→"Gate 2"`, `tags:["prod"]→null`, `welcome_msg:""→"Hi"`}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("DiffBots = %v, want %v", got, want)
+	}
+
+	if changes, _ := DiffBots(from, from); changes == nil || len(changes) != 0 {
+		t.Errorf("DiffBots of equal bots = %#v, want an empty list", changes)
+	}
+}
@@ -0,0 +1,112 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Session is a login. The cookie holds a random token; TokenHash is its
+// HMAC, so the table alone does not let anyone log in. ID is public and
+// identifies the session when listing or revoking it.
+type Session struct {
+	ID         string    `json:"id"`
+	TokenHash  string    `json:"-"`
+	Username   string    `json:"username"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+const sessionColumns = `id, token_hash, username, ip, user_agent, created_at, last_seen_at, expires_at`
+
+func scanSession(row pgx.Row) (Session, error) {
+	var s Session
+	err := row.Scan(&s.ID, &s.TokenHash, &s.Username, &s.IP, &s.UserAgent,
+		&s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt)
+	return s, err
+}
+
+func (d *DB) CreateSession(ctx context.Context, s Session) error {
+	_, err := d.Pool.Exec(ctx, `
+		INSERT INTO sessions(id, token_hash, username, ip, user_agent, expires_at)
+		VALUES($1,$2,$3,$4,$5,$6)`,
+		s.ID, s.TokenHash, s.Username, s.IP, s.UserAgent, s.ExpiresAt,
+	)
+	return err
+}
+
+// GetSessionByToken returns the unexpired session with the given token hash.
+func (d *DB) GetSessionByToken(ctx context.Context, tokenHash string) (Session, error) {
+	s, err := scanSession(d.Pool.QueryRow(ctx, `
+		SELECT `+sessionColumns+` FROM sessions
+		WHERE token_hash=$1 AND expires_at > NOW()`, tokenHash))
+	if err == pgx.ErrNoRows {
+		return s, fmt.Errorf("session not found")
+	}
+	return s, err
+}
+
+// GetSessions lists the unexpired sessions of a user, most recently used
+// first.
+func (d *DB) GetSessions(ctx context.Context, username string) ([]Session, error) {
+	rows, err := d.Pool.Query(ctx, `
+		SELECT `+sessionColumns+` FROM sessions
+		WHERE username=$1 AND expires_at > NOW()
+		ORDER BY last_seen_at DESC, id`, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []Session
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// TouchSession records that a session was used and moves its expiry.
+func (d *DB) TouchSession(ctx context.Context, id string, expiresAt time.Time) error {
+	_, err := d.Pool.Exec(ctx,
+		`UPDATE sessions SET last_seen_at=NOW(), expires_at=$2 WHERE id=$1`, id, expiresAt)
+	return err
+}
+
+// DeleteSession revokes a session of username.
+func (d *DB) DeleteSession(ctx context.Context, username, id string) error {
+	tag, err := d.Pool.Exec(ctx,
+		`DELETE FROM sessions WHERE username=$1 AND id=$2`, username, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("session %q not found", id)
+	}
+	return nil
+}
+
+// DeleteSessions revokes all sessions of username except the one with id
+// keep ("" revokes all) and returns how many were revoked.
+func (d *DB) DeleteSessions(ctx context.Context, username, keep string) (int, error) {
+	tag, err := d.Pool.Exec(ctx,
+		`DELETE FROM sessions WHERE username=$1 AND id<>$2`, username, keep)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// DeleteExpiredSessions removes sessions that can no longer be used.
+func (d *DB) DeleteExpiredSessions(ctx context.Context) error {
+	_, err := d.Pool.Exec(ctx, `DELETE FROM sessions WHERE expires_at <= NOW()`)
+	return err
+}
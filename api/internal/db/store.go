@@ -0,0 +1,94 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is wrapped by the errors for a missing bot or template.
+var ErrNotFound = errors.New("not found")
+
+// Store is everything the manager, the API and the bot runners persist. *DB
+// implements it on PostgreSQL and MemStore in memory; both pass the same
+// contract tests (store_test.go). Migrations are not part of it: they are
+// specific to PostgreSQL.
+type Store interface {
+	// Bots. Every change made through CreateBot, UpsertBot,
+	// UpdateBotIfUnchanged, SetBotEnabled and UpdateWelcomeImg is recorded as
+	// a revision.
+	GetAllBots(ctx context.Context) ([]Bot, error)
+	GetBot(ctx context.Context, id string) (Bot, error)
+	CreateBot(ctx context.Context, b Bot) error
+	UpsertBot(ctx context.Context, b Bot) error
+	UpdateBotIfUnchanged(ctx context.Context, b Bot, version time.Time) error
+	DeleteBot(ctx context.Context, id string) error
+	SetBotEnabled(ctx context.Context, id string, enabled bool) error
+	UpdateWelcomeImg(ctx context.Context, botID, key string) error
+	ReplaceBotToken(ctx context.Context, id, old, token string) (bool, error)
+
+	// Assets.
+	GetAssets(ctx context.Context, botID string) ([]Asset, error)
+	InsertAsset(ctx context.Context, a Asset) error
+	DeleteAsset(ctx context.Context, botID, minioKey string) error
+
+	// Settings.
+	GetSetting(ctx context.Context, key string) (string, error)
+	SetSetting(ctx context.Context, key, value string) error
+
+	// Users. UpdateUser and DeleteUser refuse to remove the last owner.
+	GetUsers(ctx context.Context) ([]User, error)
+	GetUser(ctx context.Context, username string) (User, error)
+	CreateUser(ctx context.Context, u User) error
+	UpdateUser(ctx context.Context, u User) error
+	DeleteUser(ctx context.Context, username string) error
+
+	// Sessions. Lookups skip expired sessions; deleting a user deletes its
+	// sessions.
+	CreateSession(ctx context.Context, s Session) error
+	GetSessionByToken(ctx context.Context, tokenHash string) (Session, error)
+	GetSessions(ctx context.Context, username string) ([]Session, error)
+	TouchSession(ctx context.Context, id string, expiresAt time.Time) error
+	DeleteSession(ctx context.Context, username, id string) error
+	DeleteSessions(ctx context.Context, username, keep string) (int, error)
+	DeleteExpiredSessions(ctx context.Context) error
+
+	// Templates.
+	GetTemplates(ctx context.Context) ([]Template, error)
+	GetTemplate(ctx context.Context, id string) (Template, error)
+	UpsertTemplate(ctx context.Context, t Template) error
+	DeleteTemplate(ctx context.Context, id string) error
+
+	// Revisions and audit log.
+	GetRevisions(ctx context.Context, botID string) ([]Revision, error)
+	GetRevision(ctx context.Context, botID string, rev int) (Revision, error)
+	RecordAudit(ctx context.Context, action, target, detail string) error
+	GetAuditLog(ctx context.Context, limit int) ([]AuditEntry, error)
+
+	// Leases.
+	AcquireLease(ctx context.Context, botID, owner, ownerURL string, ttl time.Duration) (bool, error)
+	RenewLease(ctx context.Context, botID, owner string, ttl time.Duration, status, statusMsg string) (bool, error)
+	ReleaseLease(ctx context.Context, botID, owner string) error
+	GetLease(ctx context.Context, botID string) (l Lease, ok bool, err error)
+	GetLeases(ctx context.Context) ([]Lease, error)
+
+	// Runner state: update offsets, outbox and chats.
+	GetUpdateOffset(ctx context.Context, botID string) (updateID int, ok bool, err error)
+	SetUpdateOffset(ctx context.Context, botID, tokenBotID string, updateID int) error
+	EnqueueOutbox(ctx context.Context, items ...OutboxItem) error
+	DueOutbox(ctx context.Context, botID string, limit int) ([]OutboxItem, error)
+	ListOutbox(ctx context.Context, botID string, status OutboxStatus) ([]OutboxItem, error)
+	CompleteOutboxItem(ctx context.Context, id int64) error
+	RetryOutboxItem(ctx context.Context, id int64, attempts int, next time.Time, lastErr string) error
+	DeadLetterOutboxItem(ctx context.Context, id int64, attempts int, lastErr string) error
+	RequeueOutboxItem(ctx context.Context, botID string, id int64) error
+	DeleteOutboxItem(ctx context.Context, botID string, id int64) error
+	UpsertBotChat(ctx context.Context, c BotChat) error
+	GetBotChats(ctx context.Context, botID string) ([]BotChat, error)
+	GetForwardedChat(ctx context.Context, botID string, messageID int) (BotChat, error)
+}
+
+var (
+	_ Store = (*DB)(nil)
+	_ Store = (*MemStore)(nil)
+)
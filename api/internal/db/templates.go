@@ -0,0 +1,112 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Template is a reusable bot configuration without a token or channel.
+// WelcomeImgKey and the asset keys point at objects under templates/{id}/.
+type Template struct {
+	ID                 string          `json:"id"`
+	Name               string          `json:"name"`
+	Description        string          `json:"description"`
+	Type               BotType         `json:"type"`
+	WelcomeImgKey      string          `json:"welcome_img_key"`
+	WelcomeMsg         string          `json:"welcome_msg"`
+	ButtonText         string          `json:"button_text"`
+	NotSubMsg          string          `json:"not_sub_msg"`
+	SuccessMsg         string          `json:"success_msg"`
+	StaleUpdateMinutes int             `json:"stale_update_minutes"`
+	Tags               []string        `json:"tags"`
+	Assets             []TemplateAsset `json:"assets"`
+	CreatedAt          time.Time       `json:"created_at"`
+	UpdatedAt          time.Time       `json:"updated_at"`
+}
+
+// TemplateAsset is a document stored with a template.
+type TemplateAsset struct {
+	MinioKey    string `json:"minio_key"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+}
+
+const templateColumns = `id, name, description, type, welcome_img_key, welcome_msg,
+		       button_text, not_sub_msg, success_msg, stale_update_minutes,
+		       tags, assets, created_at, updated_at`
+
+func scanTemplate(row pgx.Row) (Template, error) {
+	var t Template
+	err := row.Scan(
+		&t.ID, &t.Name, &t.Description, &t.Type, &t.WelcomeImgKey, &t.WelcomeMsg,
+		&t.ButtonText, &t.NotSubMsg, &t.SuccessMsg, &t.StaleUpdateMinutes,
+		&t.Tags, &t.Assets, &t.CreatedAt, &t.UpdatedAt,
+	)
+	return t, err
+}
+
+func (d *DB) GetTemplates(ctx context.Context) ([]Template, error) {
+	rows, err := d.Pool.Query(ctx, `
+		SELECT `+templateColumns+`
+		FROM bot_templates ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var templates []Template
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
+
+func (d *DB) GetTemplate(ctx context.Context, id string) (Template, error) {
+	t, err := scanTemplate(d.Pool.QueryRow(ctx, `
+		SELECT `+templateColumns+`
+		FROM bot_templates WHERE id=$1`, id))
+	if err == pgx.ErrNoRows {
+		return t, fmt.Errorf("template %q %w", id, ErrNotFound)
+	}
+	return t, err
+}
+
+func (d *DB) UpsertTemplate(ctx context.Context, t Template) error {
+	if t.Tags == nil {
+		t.Tags = []string{}
+	}
+	if t.Assets == nil {
+		t.Assets = []TemplateAsset{}
+	}
+	_, err := d.Pool.Exec(ctx, `
+		INSERT INTO bot_templates(id, name, description, type, welcome_img_key, welcome_msg,
+		    button_text, not_sub_msg, success_msg, stale_update_minutes, tags, assets)
+		VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)
+		ON CONFLICT(id) DO UPDATE SET
+		    name=$2, description=$3, type=$4, welcome_img_key=$5, welcome_msg=$6,
+		    button_text=$7, not_sub_msg=$8, success_msg=$9, stale_update_minutes=$10,
+		    tags=$11, assets=$12, updated_at=NOW()`,
+		t.ID, t.Name, t.Description, t.Type, t.WelcomeImgKey, t.WelcomeMsg,
+		t.ButtonText, t.NotSubMsg, t.SuccessMsg, t.StaleUpdateMinutes, t.Tags, t.Assets,
+	)
+	return err
+}
+
+func (d *DB) DeleteTemplate(ctx context.Context, id string) error {
+	tag, err := d.Pool.Exec(ctx, `DELETE FROM bot_templates WHERE id=$1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("template %q %w", id, ErrNotFound)
+	}
+	return nil
+}
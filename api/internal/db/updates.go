@@ -0,0 +1,36 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// GetUpdateOffset returns the last processed Telegram update_id for a bot.
+// ok is false when the bot has never processed an update.
+func (d *DB) GetUpdateOffset(ctx context.Context, botID string) (updateID int, ok bool, err error) {
+	err = d.Pool.QueryRow(ctx,
+		`SELECT update_id FROM bot_update_offsets WHERE bot_id=$1`, botID,
+	).Scan(&updateID)
+	if err == pgx.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return updateID, true, nil
+}
+
+// SetUpdateOffset records updateID as the last processed update for a bot
+// whose token starts with tokenBotID.
+func (d *DB) SetUpdateOffset(ctx context.Context, botID, tokenBotID string, updateID int) error {
+	_, err := d.Pool.Exec(ctx, `
+		INSERT INTO bot_update_offsets(bot_id, token_bot_id, update_id, updated_at)
+		VALUES ($1,$2,$3,NOW())
+		ON CONFLICT(bot_id) DO UPDATE SET
+		    token_bot_id=EXCLUDED.token_bot_id, update_id=EXCLUDED.update_id,
+		    updated_at=NOW()`,
+		botID, tokenBotID, updateID,
+	)
+	return err
+}
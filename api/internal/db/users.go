@@ -0,0 +1,159 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Role is what a user may do. Each role includes the rights of the roles
+// below it.
+type Role string
+
+const (
+	RoleViewer   Role = "viewer"   // read bots, logs and history
+	RoleOperator Role = "operator" // also start, stop and restart bots
+	RoleEditor   Role = "editor"   // also change bots, templates and assets
+	RoleOwner    Role = "owner"    // also manage users and see tokens
+)
+
+var roleRank = map[Role]int{RoleViewer: 1, RoleOperator: 2, RoleEditor: 3, RoleOwner: 4}
+
+// Valid reports whether r is a known role.
+func (r Role) Valid() bool { return roleRank[r] > 0 }
+
+// Includes reports whether r has all the rights of other.
+func (r Role) Includes(other Role) bool {
+	return r.Valid() && roleRank[r] >= roleRank[other]
+}
+
+// User is an account of the web UI.
+type User struct {
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"-"`
+	Role         Role      `json:"role"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+var (
+	// ErrUserExists is returned by CreateUser when the username is taken.
+	ErrUserExists = errors.New("user already exists")
+	// ErrLastOwner is returned when a change would leave no owner.
+	ErrLastOwner = errors.New("cannot remove the last owner")
+)
+
+const userColumns = `username, password_hash, role, created_at, updated_at`
+
+func scanUser(row pgx.Row) (User, error) {
+	var u User
+	err := row.Scan(&u.Username, &u.PasswordHash, &u.Role, &u.CreatedAt, &u.UpdatedAt)
+	return u, err
+}
+
+// GetUsers lists all users by username.
+func (d *DB) GetUsers(ctx context.Context) ([]User, error) {
+	rows, err := d.Pool.Query(ctx, `SELECT `+userColumns+` FROM users ORDER BY username`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func (d *DB) GetUser(ctx context.Context, username string) (User, error) {
+	u, err := scanUser(d.Pool.QueryRow(ctx,
+		`SELECT `+userColumns+` FROM users WHERE username=$1`, username))
+	if err == pgx.ErrNoRows {
+		return u, fmt.Errorf("user %q not found", username)
+	}
+	return u, err
+}
+
+func (d *DB) CreateUser(ctx context.Context, u User) error {
+	_, err := d.Pool.Exec(ctx,
+		`INSERT INTO users(username, password_hash, role) VALUES($1,$2,$3)`,
+		u.Username, u.PasswordHash, u.Role)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" { // unique_violation
+		return fmt.Errorf("%w: %q", ErrUserExists, u.Username)
+	}
+	return err
+}
+
+// UpdateUser replaces the role and password hash of a user. It fails with
+// ErrLastOwner if that would demote the only owner.
+func (d *DB) UpdateUser(ctx context.Context, u User) error {
+	return pgx.BeginFunc(ctx, d.Pool, func(tx pgx.Tx) error {
+		if u.Role != RoleOwner {
+			if err := keepOwner(ctx, tx, u.Username); err != nil {
+				return err
+			}
+		}
+		tag, err := tx.Exec(ctx, `
+			UPDATE users SET role=$2, password_hash=$3, updated_at=NOW()
+			WHERE username=$1`, u.Username, u.Role, u.PasswordHash)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return fmt.Errorf("user %q not found", u.Username)
+		}
+		return nil
+	})
+}
+
+// DeleteUser removes a user. It fails with ErrLastOwner for the only owner.
+func (d *DB) DeleteUser(ctx context.Context, username string) error {
+	return pgx.BeginFunc(ctx, d.Pool, func(tx pgx.Tx) error {
+		if err := keepOwner(ctx, tx, username); err != nil {
+			return err
+		}
+		tag, err := tx.Exec(ctx, `DELETE FROM users WHERE username=$1`, username)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return fmt.Errorf("user %q not found", username)
+		}
+		return nil
+	})
+}
+
+// keepOwner returns ErrLastOwner if username is the only owner. It locks the
+// owner rows, so concurrent demotions of two owners cannot both succeed.
+func keepOwner(ctx context.Context, tx pgx.Tx, username string) error {
+	rows, err := tx.Query(ctx, `SELECT username FROM users WHERE role='owner' FOR UPDATE`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var owners []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		owners = append(owners, name)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(owners) == 1 && owners[0] == username {
+		return ErrLastOwner
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type BulkAction string
+
+const (
+	BulkStart   BulkAction = "start"
+	BulkStop    BulkAction = "stop"
+	BulkRestart BulkAction = "restart"
+	BulkEnable  BulkAction = "enable"
+	BulkDisable BulkAction = "disable"
+	BulkDelete  BulkAction = "delete"
+)
+
+func (a BulkAction) Valid() bool {
+	switch a {
+	case BulkStart, BulkStop, BulkRestart, BulkEnable, BulkDisable, BulkDelete:
+		return true
+	}
+	return false
+}
+
+// BulkResult is the outcome of a bulk action for one bot.
+type BulkResult struct {
+	ID    string `json:"id"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// normalizeTags trims, lower-cases and de-duplicates tags.
+func normalizeTags(tags []string) []string {
+	out := make([]string, 0, len(tags))
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t != "" && !slices.Contains(out, t) {
+			out = append(out, t)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// SelectBots resolves a bulk selector: the given IDs, or every bot carrying
+// tag. Exactly one of ids and tag must be set.
+func (m *Manager) SelectBots(ctx context.Context, ids []string, tag string) ([]string, error) {
+	switch {
+	case len(ids) > 0 && tag != "":
+		return nil, fmt.Errorf("select bots either by ids or by tag, not both")
+	case len(ids) > 0:
+		return ids, nil
+	case tag == "":
+		return nil, fmt.Errorf("ids or tag is required")
+	}
+
+	bots, err := m.database.GetAllBots(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	var out []string
+	for _, b := range bots {
+		if slices.Contains(b.Tags, tag) {
+			out = append(out, b.ID)
+		}
+	}
+	return out, nil
+}
+
+// Bulk applies action to every bot in ids concurrently and returns the
+// per-bot results in the order of ids.
+func (m *Manager) Bulk(ctx context.Context, action BulkAction, ids []string) []BulkResult {
+	results := make([]BulkResult, len(ids))
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			results[i] = BulkResult{ID: id, OK: true}
+			if err := m.bulkOne(ctx, action, id); err != nil {
+				results[i] = BulkResult{ID: id, Error: err.Error()}
+			}
+		}(i, id)
+	}
+	wg.Wait()
+	return results
+}
+
+func (m *Manager) bulkOne(ctx context.Context, action BulkAction, id string) error {
+	switch action {
+	case BulkStart:
+		return m.Start(ctx, id)
+	case BulkStop:
+		return m.Stop(ctx, id)
+	case BulkRestart:
+		return m.Restart(ctx, id)
+	case BulkEnable:
+		return m.SetEnabled(ctx, id, true)
+	case BulkDisable:
+		return m.SetEnabled(ctx, id, false)
+	case BulkDelete:
+		return m.DeleteBot(ctx, id)
+	default:
+		return fmt.Errorf("unknown action %q", action)
+	}
+}
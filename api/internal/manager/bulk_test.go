@@ -0,0 +1,97 @@
+package manager
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// botToken returns a valid token for the Telegram bot with numeric ID id.
+func botToken(id string) string {
+	return id + testToken[strings.Index(testToken, ":"):]
+}
+
+func TestNormalizeTags(t *testing.T) {
+	for _, tc := range []struct {
+		in   []string
+		want string
+	}{
+		{nil, ""},
+		{[]string{" Prod ", "eu", "prod", "", "  "}, "eu,prod"},
+		{[]string{"b", "A", "c"}, "a,b,c"},
+	} {
+		if got := strings.Join(normalizeTags(tc.in), ","); got != tc.want {
+			t.Errorf("normalizeTags(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestSelectBots(t *testing.T) {
+	m, _ := newTestManager(t)
+	ctx := context.Background()
+	for i, tags := range [][]string{{"Prod", "eu"}, {"prod"}, {"staging"}} {
+		b := testBot(string(rune('a'+i)), botToken(string(rune('1'+i))+"0000"))
+		b.Tags = tags
+		if err := m.AddBot(ctx, b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		ids  []string
+		tag  string
+		want string
+		ok   bool
+	}{
+		{nil, "prod", "a,b", true},
+		{nil, " PROD ", "a,b", true}, // matched like stored tags
+		{nil, "eu", "a", true},
+		{nil, "none", "", true},
+		{[]string{"c", "missing"}, "", "c,missing", true}, // unknown IDs fail per bot later
+		{nil, "", "", false},
+		{[]string{"a"}, "prod", "", false},
+	} {
+		ids, err := m.SelectBots(ctx, tc.ids, tc.tag)
+		if (err == nil) != tc.ok {
+			t.Errorf("SelectBots(%v, %q) error = %v, want ok=%v", tc.ids, tc.tag, err, tc.ok)
+			continue
+		}
+		if got := strings.Join(ids, ","); got != tc.want {
+			t.Errorf("SelectBots(%v, %q) = %q, want %q", tc.ids, tc.tag, got, tc.want)
+		}
+	}
+}
+
+func TestBulk(t *testing.T) {
+	m, store := newTestManager(t)
+	ctx := context.Background()
+	for i, id := range []string{"a", "b"} {
+		if err := m.AddBot(ctx, testBot(id, botToken(string(rune('1'+i))+"0000"))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results := m.Bulk(ctx, BulkEnable, []string{"b", "missing", "a"})
+	if len(results) != 3 || results[0].ID != "b" || !results[0].OK ||
+		results[1].ID != "missing" || results[1].OK || results[1].Error == "" ||
+		results[2].ID != "a" || !results[2].OK {
+		t.Fatalf("bulk enable = %+v", results)
+	}
+	for _, id := range []string{"a", "b"} {
+		if b, _ := store.GetBot(ctx, id); !b.Enabled {
+			t.Errorf("%s not enabled", id)
+		}
+	}
+
+	for _, res := range m.Bulk(ctx, BulkDelete, []string{"a", "b"}) {
+		if !res.OK {
+			t.Errorf("delete %s: %s", res.ID, res.Error)
+		}
+	}
+	if bots, _ := store.GetAllBots(ctx); len(bots) != 0 || len(m.Status()) != 0 {
+		t.Errorf("bots left after bulk delete: %d stored, %d runners", len(bots), len(m.Status()))
+	}
+	if BulkAction("pause").Valid() || !BulkRestart.Valid() {
+		t.Error("BulkAction.Valid is wrong")
+	}
+}
@@ -0,0 +1,62 @@
+package manager
+
+import (
+	"context"
+	"errors"
+
+	"bot-manager/internal/botrunner"
+	"bot-manager/internal/db"
+)
+
+// ResolveChat finds the numeric ID and title of a channel. ref is an
+// "@username", a t.me link or a chat ID and is looked up with getChat using
+// the bot's token (the stored one for an existing bot when token is empty or
+// masked). A forwardedMsgID instead returns the channel of the post the bot
+// received in that private message.
+func (m *Manager) ResolveChat(ctx context.Context, botID, token, ref string, forwardedMsgID int) (botrunner.ResolvedChat, error) {
+	if forwardedMsgID != 0 {
+		if botID == "" {
+			return botrunner.ResolvedChat{}, errors.New("id is required with forwarded_message_id")
+		}
+		c, err := m.database.GetForwardedChat(ctx, botID, forwardedMsgID)
+		if err != nil {
+			return botrunner.ResolvedChat{}, err
+		}
+		return botrunner.ResolvedChat{ID: c.ChatID, Title: c.Title, Type: c.Type, Username: c.Username}, nil
+	}
+
+	cfg := db.Bot{ID: botID, Token: token}
+	if botID != "" {
+		if cur, err := m.database.GetBot(ctx, botID); err == nil {
+			cfg.APIEndpoint, cfg.Proxy = cur.APIEndpoint, cur.Proxy
+		}
+		if err := m.resolveToken(ctx, &cfg); err != nil {
+			return botrunner.ResolvedChat{}, err
+		}
+	}
+	cfg = m.decrypted(cfg)
+	if cfg.Token == "" {
+		return botrunner.ResolvedChat{}, errors.New("token is required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, verifyTimeout)
+	defer cancel()
+	return botrunner.ResolveChat(ctx, cfg.Token, m.cfg.Telegram.For(cfg), ref)
+}
+
+// AdminChats lists the chats the bot is currently an administrator of, as
+// reported by my_chat_member updates. Channels only known from forwarded
+// posts are left out; ResolveChat finds those by forwarded message.
+func (m *Manager) AdminChats(ctx context.Context, botID string) ([]db.BotChat, error) {
+	chats, err := m.database.GetBotChats(ctx, botID)
+	if err != nil {
+		return nil, err
+	}
+	out := []db.BotChat{}
+	for _, c := range chats {
+		if c.IsAdmin() {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
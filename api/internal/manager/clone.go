@@ -0,0 +1,113 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"bot-manager/internal/db"
+)
+
+// ErrBotExists is returned by CloneBot, CreateFromTemplate and CreateBot when
+// the target ID is taken.
+var ErrBotExists = db.ErrBotExists
+
+// CloneParams are the fields that must differ between a bot and its clone.
+type CloneParams struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Token     string `json:"token"`
+	ChannelID int64  `json:"channel_id"`
+}
+
+func botPrefix(id string) string      { return id + "/" }
+func templatePrefix(id string) string { return "templates/" + id + "/" }
+
+// rekey moves an object key from one prefix to another.
+func rekey(key, srcPrefix, dstPrefix string) string {
+	return dstPrefix + strings.TrimPrefix(key, srcPrefix)
+}
+
+// CloneBot creates a disabled copy of bot srcID under p.ID, copying its
+// documents and welcome image to the new bot's prefix server-side.
+func (m *Manager) CloneBot(ctx context.Context, srcID string, p CloneParams) (db.Bot, error) {
+	src, err := m.database.GetBot(ctx, srcID)
+	if err != nil {
+		return db.Bot{}, err
+	}
+	assets, err := m.database.GetAssets(ctx, srcID)
+	if err != nil {
+		return db.Bot{}, err
+	}
+
+	clone := src
+	if p.Name != "" {
+		clone.Name = p.Name
+	}
+	return m.createWithAssets(ctx, clone, p, botPrefix(srcID), assets)
+}
+
+// createWithAssets adds cfg as a new disabled bot with the identity from p,
+// copies the objects under srcPrefix to the bot's prefix and registers the
+// copied documents. It fails with ErrBotExists if p.ID is taken; on any
+// failure, only what this call created is removed.
+func (m *Manager) createWithAssets(ctx context.Context, cfg db.Bot, p CloneParams, srcPrefix string, assets []db.Asset) (db.Bot, error) {
+	dstPrefix := botPrefix(p.ID)
+
+	cfg.ID = p.ID
+	cfg.Token = p.Token
+	cfg.ChannelID = p.ChannelID
+	cfg.Enabled = false
+	if cfg.WelcomeImgKey != "" {
+		cfg.WelcomeImgKey = rekey(cfg.WelcomeImgKey, srcPrefix, dstPrefix)
+	}
+	if err := m.CreateBot(ctx, cfg); err != nil {
+		return db.Bot{}, err
+	}
+
+	copied, err := m.copyObjects(ctx, srcPrefix, dstPrefix)
+	if err == nil {
+		for _, a := range assets {
+			a.BotID = p.ID
+			a.MinioKey = rekey(a.MinioKey, srcPrefix, dstPrefix)
+			if err = m.database.InsertAsset(ctx, a); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		m.deleteObjects(ctx, copied)
+		m.DeleteBot(ctx, p.ID)
+		return db.Bot{}, fmt.Errorf("copy assets: %w", err)
+	}
+	return m.database.GetBot(ctx, p.ID)
+}
+
+// copyObjects copies the docs/ and welcome/ objects under srcPrefix to
+// dstPrefix and returns the new keys. If a copy fails, the objects copied so
+// far are removed again.
+func (m *Manager) copyObjects(ctx context.Context, srcPrefix, dstPrefix string) ([]string, error) {
+	var copied []string
+	for _, dir := range []string{"docs/", "welcome/"} {
+		objects, err := m.store.ListObjects(ctx, srcPrefix+dir)
+		if err != nil {
+			m.deleteObjects(ctx, copied)
+			return nil, err
+		}
+		for _, obj := range objects {
+			dst := rekey(obj.Key, srcPrefix, dstPrefix)
+			if err := m.store.CopyObject(ctx, obj.Key, dst); err != nil {
+				m.deleteObjects(ctx, copied)
+				return nil, err
+			}
+			copied = append(copied, dst)
+		}
+	}
+	return copied, nil
+}
+
+func (m *Manager) deleteObjects(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		m.store.Delete(ctx, key) //nolint:errcheck
+	}
+}
@@ -0,0 +1,60 @@
+package manager
+
+import (
+	"sync"
+
+	"bot-manager/internal/botrunner"
+)
+
+// subscriberBuffer is how many events a subscriber may lag behind before new
+// events are dropped for it.
+const subscriberBuffer = 64
+
+// Bus fans runner events out to subscribers (e.g. SSE clients). Publishing
+// never blocks: a subscriber that does not keep up loses events.
+type Bus struct {
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+}
+
+// Subscription receives events on C until it is passed to Unsubscribe.
+type Subscription struct {
+	C     <-chan botrunner.Event
+	ch    chan botrunner.Event
+	botID string // "" = all bots
+}
+
+func NewBus() *Bus {
+	return &Bus{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscribe registers a subscriber for the events of botID, or of every bot
+// if botID is empty.
+func (b *Bus) Subscribe(botID string) *Subscription {
+	ch := make(chan botrunner.Event, subscriberBuffer)
+	sub := &Subscription{C: ch, ch: ch, botID: botID}
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+	return sub
+}
+
+func (b *Bus) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	delete(b.subs, sub)
+	b.mu.Unlock()
+}
+
+func (b *Bus) Publish(e botrunner.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subs {
+		if sub.botID != "" && sub.botID != e.BotID {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+		}
+	}
+}
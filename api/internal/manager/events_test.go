@@ -0,0 +1,45 @@
+package manager
+
+import (
+	"testing"
+
+	"bot-manager/internal/botrunner"
+)
+
+func TestBus(t *testing.T) {
+	bus := NewBus()
+	all := bus.Subscribe("")
+	gate := bus.Subscribe("gate")
+	slow := bus.Subscribe("")
+
+	bus.Publish(botrunner.Event{Type: botrunner.EventLog, BotID: "gate", Line: "1"})
+	bus.Publish(botrunner.Event{Type: botrunner.EventLog, BotID: "other", Line: "2"})
+
+	for _, want := range []string{"1", "2"} {
+		if e := <-all.C; e.Line != want {
+			t.Errorf("all: got %q, want %q", e.Line, want)
+		}
+	}
+	if e := <-gate.C; e.Line != "1" {
+		t.Errorf("gate: got %q", e.Line)
+	}
+	select {
+	case e := <-gate.C:
+		t.Errorf("gate got an event of %s", e.BotID)
+	default:
+	}
+
+	// A subscriber that stops reading loses events instead of blocking the
+	// publisher.
+	bus.Unsubscribe(all)
+	bus.Unsubscribe(gate)
+	for i := 0; i < subscriberBuffer*2; i++ {
+		bus.Publish(botrunner.Event{Type: botrunner.EventLog, BotID: "gate"})
+	}
+	if n := len(slow.C); n != subscriberBuffer {
+		t.Errorf("slow subscriber has %d events, want %d", n, subscriberBuffer)
+	}
+	if len(all.C)+len(gate.C) != 0 {
+		t.Error("unsubscribed subscribers still receive events")
+	}
+}
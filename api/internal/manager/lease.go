@@ -0,0 +1,245 @@
+package manager
+
+// lease.go — bot ownership across replicas.
+// Every replica runs the same sync loop: it renews the leases it holds, takes
+// free or expired leases of enabled bots and starts them, and stops bots
+// whose lease it has lost. The result is that each bot polls Telegram from
+// exactly one replica, and survivors take over when a replica dies.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"time"
+
+	"bot-manager/internal/botrunner"
+	"bot-manager/internal/db"
+)
+
+// NotOwnerError is returned for operations on a bot that is leased by
+// another replica.
+type NotOwnerError struct {
+	Lease db.Lease
+}
+
+func (e *NotOwnerError) Error() string {
+	return fmt.Sprintf("bot %q is running on replica %q", e.Lease.BotID, e.Lease.Owner)
+}
+
+// RemoteOwner returns the lease of a bot if another live replica holds it.
+func (m *Manager) RemoteOwner(ctx context.Context, id string) (db.Lease, bool) {
+	l, ok, err := m.database.GetLease(ctx, id)
+	if err != nil || !ok || !l.Alive || l.Owner == m.cfg.InstanceID {
+		return db.Lease{}, false
+	}
+	return l, true
+}
+
+func (m *Manager) isOwned(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.owned[id]
+	return ok
+}
+
+// acquire takes the lease for a bot unless this replica already holds it.
+func (m *Manager) acquire(ctx context.Context, id string) error {
+	if m.isOwned(id) {
+		return nil
+	}
+	at := time.Now()
+	ok, err := m.database.AcquireLease(ctx, id, m.cfg.InstanceID, m.cfg.InstanceURL, m.cfg.LeaseTTL)
+	if err != nil {
+		return fmt.Errorf("acquire lease: %w", err)
+	}
+	if !ok {
+		l, _, _ := m.database.GetLease(ctx, id)
+		return &NotOwnerError{Lease: l}
+	}
+	m.mu.Lock()
+	m.owned[id] = at
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Manager) release(ctx context.Context, id string) {
+	m.mu.Lock()
+	delete(m.owned, id)
+	m.mu.Unlock()
+	if err := m.database.ReleaseLease(ctx, id, m.cfg.InstanceID); err != nil {
+		log.Printf("manager: release lease %s: %v", id, err)
+	}
+}
+
+func (m *Manager) leaseLoop(ctx context.Context) {
+	t := time.NewTicker(m.cfg.LeaseTTL / 3)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			m.syncLeases(ctx)
+		}
+	}
+}
+
+// syncLeases reconciles local runners with the bots table and the lease table.
+func (m *Manager) syncLeases(ctx context.Context) {
+	bots, err := m.database.GetAllBots(ctx)
+	if err != nil {
+		log.Printf("manager: load bots: %v", err)
+		return
+	}
+	for i := range bots {
+		bots[i] = m.decrypted(bots[i])
+	}
+	leases, err := m.database.GetLeases(ctx)
+	if err != nil {
+		log.Printf("manager: load leases: %v", err)
+		return
+	}
+
+	// Pick up bots created, changed or deleted through other replicas.
+	var changed []db.Bot
+	var removed []*botrunner.BotRunner
+	m.mu.Lock()
+	m.leases = make(map[string]db.Lease, len(leases))
+	for _, l := range leases {
+		m.leases[l.BotID] = l
+	}
+	seen := make(map[string]bool, len(bots))
+	for _, cfg := range bots {
+		seen[cfg.ID] = true
+		r, ok := m.runners[cfg.ID]
+		if !ok {
+			m.runners[cfg.ID] = m.newRunner(cfg)
+		} else if !r.Cfg.UpdatedAt.Equal(cfg.UpdatedAt) {
+			changed = append(changed, cfg)
+		}
+	}
+	for id, r := range m.runners {
+		if !seen[id] {
+			removed = append(removed, r)
+			delete(m.runners, id)
+			delete(m.owned, id)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, r := range removed {
+		go r.Stop()
+	}
+	for _, cfg := range changed {
+		m.applyRemoteUpdate(cfg)
+	}
+
+	for _, cfg := range bots {
+		m.syncLease(ctx, cfg)
+	}
+}
+
+// applyRemoteUpdate swaps in a config edited on another replica, restarting
+// the runner if it is running here.
+func (m *Manager) applyRemoteUpdate(cfg db.Bot) {
+	r, err := m.runner(cfg.ID)
+	if err != nil {
+		return
+	}
+	if !m.isOwned(cfg.ID) || r.Status() == botrunner.StatusStopped || !runtimeChanged(r.Cfg, cfg) {
+		r.UpdateConfig(cfg)
+		return
+	}
+	log.Printf("manager: %s changed on another replica, restarting", cfg.ID)
+	go func() {
+		r.Stop()
+		r.UpdateConfig(cfg)
+		if err := r.Start(); err != nil {
+			log.Printf("manager: restart %s: %v", cfg.ID, err)
+		}
+	}()
+}
+
+// runtimeChanged reports whether a running bot must restart to apply the new
+// config. Bookkeeping fields such as the name, tags and the enabled flag are
+// not read by the runner.
+func runtimeChanged(old, cur db.Bot) bool {
+	for _, b := range []*db.Bot{&old, &cur} {
+		b.Name, b.Tags, b.Enabled = "", nil, false
+		b.CreatedAt, b.UpdatedAt = time.Time{}, time.Time{}
+	}
+	return !reflect.DeepEqual(old, cur)
+}
+
+// leaseSafeFor is how long after its last successful renewal a lease is
+// still treated as held when renewing fails. It leaves a third of the TTL
+// for the runner to stop and for clock drift between this replica and the
+// database.
+func (m *Manager) leaseSafeFor() time.Duration {
+	return m.cfg.LeaseTTL * 2 / 3
+}
+
+func (m *Manager) syncLease(ctx context.Context, cfg db.Bot) {
+	r, err := m.runner(cfg.ID)
+	if err != nil {
+		return
+	}
+
+	m.mu.Lock()
+	renewedAt, owned := m.owned[cfg.ID]
+	m.mu.Unlock()
+
+	if owned {
+		at := time.Now()
+		ok, err := m.database.RenewLease(ctx, cfg.ID, m.cfg.InstanceID, m.cfg.LeaseTTL,
+			string(r.Status()), r.StatusMsg())
+		switch {
+		case err != nil:
+			log.Printf("manager: renew lease %s: %v", cfg.ID, err)
+			if time.Since(renewedAt) < m.leaseSafeFor() {
+				return
+			}
+			// Too close to expiry: stop before another replica can take the
+			// lease and poll alongside us.
+			fallthrough
+		case !ok:
+			log.Printf("manager: lost lease for %s, stopping", cfg.ID)
+			m.mu.Lock()
+			delete(m.owned, cfg.ID)
+			m.mu.Unlock()
+			go r.Stop()
+		case !cfg.Enabled && r.Status() == botrunner.StatusStopped:
+			m.release(ctx, cfg.ID)
+		default:
+			m.mu.Lock()
+			if _, still := m.owned[cfg.ID]; still {
+				m.owned[cfg.ID] = at
+			}
+			m.mu.Unlock()
+		}
+		return
+	}
+
+	if !cfg.Enabled {
+		return
+	}
+	m.mu.Lock()
+	l, leased := m.leases[cfg.ID]
+	m.mu.Unlock()
+	if leased && l.Alive && l.Owner != m.cfg.InstanceID {
+		return
+	}
+	if err := m.acquire(ctx, cfg.ID); err != nil {
+		var notOwner *NotOwnerError
+		if !errors.As(err, &notOwner) {
+			log.Printf("manager: %v", err)
+		}
+		return
+	}
+	log.Printf("manager: acquired lease for %s", cfg.ID)
+	if err := r.Start(); err != nil {
+		log.Printf("manager: start %s: %v", cfg.ID, err)
+	}
+}
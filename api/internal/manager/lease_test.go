@@ -0,0 +1,233 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"bot-manager/internal/botrunner"
+	"bot-manager/internal/db"
+	"bot-manager/internal/tgtest"
+)
+
+// newReplica returns a manager with its own instance ID on a shared store,
+// connecting to tg.
+func newReplica(t *testing.T, store db.Store, tg *tgtest.Server, id string, ttl time.Duration) *Manager {
+	t.Helper()
+	m := New(store, &memObjects{data: make(map[string][]byte)}, Config{
+		InstanceID:  id,
+		InstanceURL: "http://" + id + ":8080",
+		LeaseTTL:    ttl,
+		Telegram:    botrunner.Connection{Endpoint: tg.URL},
+	})
+	t.Cleanup(m.StopAll)
+	return m
+}
+
+func waitStatus(t *testing.T, m *Manager, id string, want botrunner.BotStatus) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		r, err := m.runner(id)
+		if err == nil && r.Status() == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s on %s: status %v, want %v", id, m.cfg.InstanceID, r.Status(), want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLeaseOwnership(t *testing.T) {
+	tg := tgtest.NewServer(testToken)
+	defer tg.Close()
+	store := db.NewMemStore()
+	ctx := context.Background()
+	a := newReplica(t, store, tg, "a", time.Minute)
+	b := newReplica(t, store, tg, "b", time.Minute)
+
+	cfg := testBot("gate", testToken)
+	cfg.Proxy, cfg.Enabled = "", true
+	if err := a.AddBot(ctx, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	a.syncLeases(ctx)
+	waitStatus(t, a, "gate", botrunner.StatusRunning)
+	if l, ok := a.RemoteOwner(ctx, "gate"); ok {
+		t.Errorf("a sees its own lease as remote: %+v", l)
+	}
+
+	// b picks up the bot from the table but leaves it to a.
+	b.syncLeases(ctx)
+	if b.isOwned("gate") {
+		t.Fatal("b took a live lease")
+	}
+	l, ok := b.RemoteOwner(ctx, "gate")
+	if !ok || l.Owner != "a" || l.OwnerURL != "http://a:8080" {
+		t.Errorf("RemoteOwner on b = %+v, %v", l, ok)
+	}
+	var notOwner *NotOwnerError
+	if err := b.Start(ctx, "gate"); !errors.As(err, &notOwner) || notOwner.Lease.Owner != "a" {
+		t.Errorf("Start on b = %v, want NotOwnerError", err)
+	}
+	if err := b.Stop(ctx, "gate"); !errors.As(err, &notOwner) {
+		t.Errorf("Stop on b = %v, want NotOwnerError", err)
+	}
+
+	// A replica shutting down releases its leases; the next sync elsewhere
+	// takes the bot over.
+	a.StopAll()
+	if _, ok := b.RemoteOwner(ctx, "gate"); ok {
+		t.Error("lease still held after StopAll")
+	}
+	b.syncLeases(ctx)
+	waitStatus(t, b, "gate", botrunner.StatusRunning)
+	if l, ok := a.RemoteOwner(ctx, "gate"); !ok || l.Owner != "b" {
+		t.Errorf("RemoteOwner on a = %+v, %v", l, ok)
+	}
+}
+
+func TestLostLeaseStopsBot(t *testing.T) {
+	tg := tgtest.NewServer(testToken)
+	defer tg.Close()
+	store := db.NewMemStore()
+	ctx := context.Background()
+	const ttl = 200 * time.Millisecond
+	a := newReplica(t, store, tg, "a", ttl)
+	b := newReplica(t, store, tg, "b", ttl)
+
+	cfg := testBot("gate", testToken)
+	cfg.Proxy, cfg.Enabled = "", true
+	if err := a.AddBot(ctx, cfg); err != nil {
+		t.Fatal(err)
+	}
+	a.syncLeases(ctx)
+	waitStatus(t, a, "gate", botrunner.StatusRunning)
+
+	// a stalls past the TTL without renewing; b takes the expired lease.
+	time.Sleep(ttl + 50*time.Millisecond)
+	b.syncLeases(ctx)
+	if !b.isOwned("gate") {
+		t.Fatal("b did not take the expired lease")
+	}
+	waitStatus(t, b, "gate", botrunner.StatusRunning)
+
+	// On its next sync a fails to renew and stops its runner.
+	a.syncLeases(ctx)
+	if a.isOwned("gate") {
+		t.Error("a still owns the bot after losing the lease")
+	}
+	waitStatus(t, a, "gate", botrunner.StatusStopped)
+}
+
+// renewFails is a store whose lease renewals fail, as if the database were
+// unreachable.
+type renewFails struct{ db.Store }
+
+func (renewFails) RenewLease(context.Context, string, string, time.Duration, string, string) (bool, error) {
+	return false, errors.New("connection refused")
+}
+
+func TestFailedRenewalStopsBotBeforeExpiry(t *testing.T) {
+	tg := tgtest.NewServer(testToken)
+	defer tg.Close()
+	store := db.NewMemStore()
+	ctx := context.Background()
+	const ttl = time.Minute
+	a := newReplica(t, store, tg, "a", ttl)
+
+	cfg := testBot("gate", testToken)
+	cfg.Proxy, cfg.Enabled = "", true
+	if err := a.AddBot(ctx, cfg); err != nil {
+		t.Fatal(err)
+	}
+	a.syncLeases(ctx)
+	waitStatus(t, a, "gate", botrunner.StatusRunning)
+	a.database = renewFails{store}
+
+	renewedAgo := func(d time.Duration) {
+		a.mu.Lock()
+		a.owned["gate"] = time.Now().Add(-d)
+		a.mu.Unlock()
+	}
+
+	// Within two thirds of the TTL a failed renewal is tolerated.
+	renewedAgo(a.leaseSafeFor() - time.Second)
+	a.syncLeases(ctx)
+	if !a.isOwned("gate") {
+		t.Fatal("bot stopped on the first failed renewal")
+	}
+
+	// Past that the runner stops, well before the lease expires.
+	renewedAgo(a.leaseSafeFor() + time.Second)
+	a.syncLeases(ctx)
+	if a.isOwned("gate") {
+		t.Error("bot still owned with a third of the TTL left")
+	}
+	waitStatus(t, a, "gate", botrunner.StatusStopped)
+}
+
+func TestDisabledBotReleasesLease(t *testing.T) {
+	tg := tgtest.NewServer(testToken)
+	defer tg.Close()
+	store := db.NewMemStore()
+	ctx := context.Background()
+	a := newReplica(t, store, tg, "a", time.Minute)
+
+	cfg := testBot("gate", testToken)
+	cfg.Proxy, cfg.Enabled = "", true
+	if err := a.AddBot(ctx, cfg); err != nil {
+		t.Fatal(err)
+	}
+	a.syncLeases(ctx)
+	waitStatus(t, a, "gate", botrunner.StatusRunning)
+
+	// Stopped by hand while enabled: the lease is kept so no other replica
+	// restarts the bot.
+	if err := a.Stop(ctx, "gate"); err != nil {
+		t.Fatal(err)
+	}
+	a.syncLeases(ctx)
+	if _, ok, _ := store.GetLease(ctx, "gate"); !ok || !a.isOwned("gate") {
+		t.Fatal("enabled bot lost its lease when stopped")
+	}
+
+	// Once disabled, the next sync gives the lease up.
+	if err := a.SetEnabled(ctx, "gate", false); err != nil {
+		t.Fatal(err)
+	}
+	a.syncLeases(ctx)
+	if l, ok, _ := store.GetLease(ctx, "gate"); ok && l.Alive {
+		t.Errorf("disabled bot keeps lease %+v", l)
+	}
+}
+
+func TestRuntimeChanged(t *testing.T) {
+	base := testBot("gate", testToken)
+	base.Name, base.Tags, base.Enabled = "Gate", []string{"prod"}, true
+	for _, tc := range []struct {
+		name   string
+		change func(*db.Bot)
+		want   bool
+	}{
+		{"nothing", func(*db.Bot) {}, false},
+		{"name", func(b *db.Bot) { b.Name = "Gate 2" }, false},
+		{"tags", func(b *db.Bot) { b.Tags = nil }, false},
+		{"enabled", func(b *db.Bot) { b.Enabled = false }, false},
+		{"updated_at", func(b *db.Bot) { b.UpdatedAt = time.Now() }, false},
+		{"token", func(b *db.Bot) { b.Token = "987654321:AAbbccddeeffgghhiijjkkllmmnnooppqqr" }, true},
+		{"channel", func(b *db.Bot) { b.ChannelID = -1009876543210 }, true},
+		{"welcome", func(b *db.Bot) { b.WelcomeMsg = "Hi" }, true},
+		{"proxy", func(b *db.Bot) { b.Proxy = db.ProxyDirect }, true},
+	} {
+		cur := base
+		cur.Tags = append([]string(nil), base.Tags...)
+		tc.change(&cur)
+		if got := runtimeChanged(base, cur); got != tc.want {
+			t.Errorf("%s: runtimeChanged = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -2,13 +2,17 @@ package manager
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"bot-manager/internal/botrunner"
 	"bot-manager/internal/db"
-	"bot-manager/internal/storage"
+	"bot-manager/internal/secrets"
+	"bot-manager/internal/validation"
 )
 
 type BotStatusSnapshot struct {
@@ -18,45 +22,104 @@ type BotStatusSnapshot struct {
 	Status    botrunner.BotStatus `json:"status"`
 	StatusMsg string              `json:"status_msg"`
 	Enabled   bool                `json:"enabled"`
+	Tags      []string            `json:"tags"`
+	Owner     string              `json:"owner,omitempty"` // replica holding the bot's lease
+
+	// Runtime details; only known on the replica running the bot and left
+	// out for bots running elsewhere.
+	Username      string     `json:"username,omitempty"`
+	StartedAt     *time.Time `json:"started_at,omitempty"`
+	UptimeSeconds *int64     `json:"uptime_seconds,omitempty"`
+	LastUpdateAt  *time.Time `json:"last_update_at,omitempty"`
+	RestartCount  *int       `json:"restart_count,omitempty"`
+	Counters      *Counters  `json:"counters,omitempty"`
+}
+
+// Counters are totals since the bot was last started.
+type Counters struct {
+	Updates    int64 `json:"updates"`
+	Callbacks  int64 `json:"callbacks"`
+	Deliveries int64 `json:"deliveries"`
+	Errors     int64 `json:"errors"`
+}
+
+// ErrTokenConflict is returned by AddBot/UpdateBot when another bot row is
+// already configured with the same Telegram bot.
+var ErrTokenConflict = errors.New("token conflict")
+
+// Config holds the replica-level settings of a Manager.
+type Config struct {
+	// InstanceID identifies this replica in bot leases.
+	InstanceID string
+	// InstanceURL is the base URL other replicas use to reach this replica's
+	// API when they forward requests for bots it owns.
+	InstanceURL string
+	// LeaseTTL is how long a bot lease stays valid without renewal.
+	LeaseTTL time.Duration
+	// Watchdog configures stuck-bot detection; a zero Interval disables it.
+	Watchdog WatchdogConfig
+	// Tokens encrypts bot tokens at rest; nil stores them in plaintext.
+	Tokens *secrets.Keyring
+	// Telegram is how bots reach the Bot API unless their config overrides it.
+	Telegram botrunner.Connection
+}
+
+// Objects is the asset storage: bots read from it, and the manager copies and
+// deletes bot and template objects in it. *storage.MinioStore implements it.
+type Objects interface {
+	botrunner.Objects
+	CopyObject(ctx context.Context, src, dst string) error
+	Delete(ctx context.Context, key string) error
 }
 
 type Manager struct {
-	database *db.DB
-	store    *storage.MinioStore
+	database db.Store
+	store    Objects
+	cfg      Config
 	runners  map[string]*botrunner.BotRunner
+	owned    map[string]time.Time // bots leased by this replica → last renewal
+	leases   map[string]db.Lease  // lease table as of the last sync
+	events   *Bus
+	watchdog *watchdog
 	mu       sync.Mutex
 }
 
-func New(database *db.DB, store *storage.MinioStore) *Manager {
+func New(database db.Store, store Objects, cfg Config) *Manager {
 	return &Manager{
 		database: database,
 		store:    store,
+		cfg:      cfg,
 		runners:  make(map[string]*botrunner.BotRunner),
+		owned:    make(map[string]time.Time),
+		leases:   make(map[string]db.Lease),
+		events:   NewBus(),
+		watchdog: newWatchdog(cfg.Watchdog),
 	}
 }
 
-// StartAll loads all bots from DB and starts enabled ones.
-func (m *Manager) StartAll(ctx context.Context) {
-	bots, err := m.database.GetAllBots(ctx)
-	if err != nil {
-		log.Printf("manager: load bots: %v", err)
-		return
-	}
+// Events returns the bus carrying status, log and user events of the bots
+// running on this replica.
+func (m *Manager) Events() *Bus { return m.events }
 
-	m.mu.Lock()
-	defer m.mu.Unlock()
+func (m *Manager) newRunner(cfg db.Bot) *botrunner.BotRunner {
+	return botrunner.New(cfg, m.database, m.store, botrunner.Options{
+		OnEvent:    m.events.Publish,
+		Connection: m.cfg.Telegram,
+	})
+}
 
-	for _, cfg := range bots {
-		r := botrunner.New(cfg, m.store)
-		m.runners[cfg.ID] = r
-		if cfg.Enabled {
-			if err := r.Start(); err != nil {
-				log.Printf("manager: start %s: %v", cfg.ID, err)
-			}
-		}
+// StartAll loads all bots from DB, starts the enabled ones whose lease this
+// replica can take, and keeps leases in sync until ctx is cancelled.
+func (m *Manager) StartAll(ctx context.Context) {
+	m.syncLeases(ctx)
+	go m.leaseLoop(ctx)
+	if m.cfg.Watchdog.Interval > 0 {
+		go m.watchdogLoop(ctx)
 	}
 }
 
+// StopAll stops every local runner and releases this replica's leases so the
+// surviving replicas can take the bots over immediately.
 func (m *Manager) StopAll() {
 	m.mu.Lock()
 	runners := make([]*botrunner.BotRunner, 0, len(m.runners))
@@ -74,66 +137,224 @@ func (m *Manager) StopAll() {
 		}(r)
 	}
 	wg.Wait()
-}
 
-func (m *Manager) Start(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 	m.mu.Lock()
-	r, ok := m.runners[id]
+	owned := make([]string, 0, len(m.owned))
+	for id := range m.owned {
+		owned = append(owned, id)
+	}
 	m.mu.Unlock()
-	if !ok {
-		return fmt.Errorf("bot %q not found", id)
+	for _, id := range owned {
+		m.release(ctx, id)
 	}
-	return r.Start()
 }
 
-func (m *Manager) Stop(id string) error {
+// isActive reports whether a runner is polling Telegram.
+func isActive(s botrunner.BotStatus) bool {
+	return s == botrunner.StatusRunning || s == botrunner.StatusStarting || s == botrunner.StatusDegraded
+}
+
+func (m *Manager) runner(id string) (*botrunner.BotRunner, error) {
 	m.mu.Lock()
 	r, ok := m.runners[id]
 	m.mu.Unlock()
 	if !ok {
-		return fmt.Errorf("bot %q not found", id)
+		return nil, fmt.Errorf("bot %q %w", id, db.ErrNotFound)
 	}
-	return r.Stop()
+	return r, nil
 }
 
-func (m *Manager) Restart(id string) error {
-	if err := m.Stop(id); err != nil {
+func (m *Manager) Start(ctx context.Context, id string) error {
+	r, err := m.runner(id)
+	if err != nil {
+		return err
+	}
+	if err := m.acquire(ctx, id); err != nil {
 		return err
 	}
-	return m.Start(id)
+	return r.Start()
+}
+
+func (m *Manager) Stop(ctx context.Context, id string) error {
+	r, err := m.runner(id)
+	if err != nil {
+		return err
+	}
+	if !m.isOwned(id) {
+		if l, remote := m.RemoteOwner(ctx, id); remote {
+			return &NotOwnerError{Lease: l}
+		}
+	}
+	if err := r.Stop(); err != nil {
+		return err
+	}
+	// An enabled bot keeps its lease while stopped by hand, otherwise another
+	// replica would pick it up and start it again.
+	if !r.Cfg.Enabled {
+		m.release(ctx, id)
+	}
+	return nil
+}
+
+func (m *Manager) Restart(ctx context.Context, id string) error {
+	if err := m.Stop(ctx, id); err != nil {
+		return err
+	}
+	return m.Start(ctx, id)
+}
+
+// tokenBotID returns the numeric bot ID part of a token ("123456:ABC…" → "123456").
+// Two tokens with the same bot ID belong to the same Telegram bot even if one
+// of them has been revoked and reissued.
+func tokenBotID(token string) string {
+	id, _, _ := strings.Cut(strings.TrimSpace(token), ":")
+	return id
+}
+
+// checkTokenConflict rejects cfg if any other bot uses the same Telegram bot.
+func (m *Manager) checkTokenConflict(ctx context.Context, cfg db.Bot) error {
+	bots, err := m.database.GetAllBots(ctx)
+	if err != nil {
+		return err
+	}
+	want := tokenBotID(m.decrypted(cfg).Token)
+	if want == "" {
+		return nil
+	}
+	for _, b := range bots {
+		if b.ID != cfg.ID && tokenBotID(m.decrypted(b).Token) == want {
+			return fmt.Errorf("%w: telegram bot %s is already used by %q", ErrTokenConflict, want, b.ID)
+		}
+	}
+	return nil
 }
 
 func (m *Manager) AddBot(ctx context.Context, cfg db.Bot) error {
-	if err := m.database.UpsertBot(ctx, cfg); err != nil {
+	return m.addBot(ctx, cfg, m.database.UpsertBot)
+}
+
+// CreateBot is AddBot for a new bot only. It fails with ErrBotExists if the
+// ID is stored already, whichever replica runs that bot.
+func (m *Manager) CreateBot(ctx context.Context, cfg db.Bot) error {
+	return m.addBot(ctx, cfg, m.database.CreateBot)
+}
+
+func (m *Manager) addBot(ctx context.Context, cfg db.Bot, store func(context.Context, db.Bot) error) error {
+	if err := m.prepare(ctx, &cfg); err != nil {
+		return err
+	}
+	cfg.TokenBotID = tokenBotID(m.decrypted(cfg).Token)
+	token, err := m.sealToken(cfg.Token)
+	if err != nil {
+		return err
+	}
+	cfg.Token = token
+	if err := store(ctx, cfg); err != nil {
+		return err
+	}
+	stored, err := m.loadBot(ctx, cfg.ID)
+	if err != nil {
 		return err
 	}
 	m.mu.Lock()
-	if _, exists := m.runners[cfg.ID]; !exists {
-		m.runners[cfg.ID] = botrunner.New(cfg, m.store)
+	if r, exists := m.runners[cfg.ID]; !exists {
+		m.runners[cfg.ID] = m.newRunner(stored)
+	} else {
+		r.UpdateConfig(stored)
 	}
 	m.mu.Unlock()
 	return nil
 }
 
+// resolveToken replaces an empty or masked token with the stored one, so
+// clients never have to send the secret back. The stored token is only sent
+// where it went before: if cfg changes the API endpoint or the proxy, the
+// token has to be re-entered. For a new bot the token is left as it is and
+// fails validation. Call it after resolveProxy.
+func (m *Manager) resolveToken(ctx context.Context, cfg *db.Bot) error {
+	if !keepsToken(cfg.Token) {
+		return nil
+	}
+	cur, err := m.database.GetBot(ctx, cfg.ID)
+	if err != nil {
+		return nil
+	}
+	if cfg.APIEndpoint != cur.APIEndpoint || cfg.Proxy != cur.Proxy {
+		return validation.Errors{{Field: "token", Code: validation.CodeRequired,
+			Message: "re-enter the token to change api_endpoint or proxy"}}
+	}
+	cfg.Token = cur.Token
+	return nil
+}
+
+// prepare normalises and validates cfg and checks it against the other bots.
+func (m *Manager) prepare(ctx context.Context, cfg *db.Bot) error {
+	cfg.Tags = normalizeTags(cfg.Tags)
+	m.resolveProxy(ctx, cfg)
+	if err := m.resolveToken(ctx, cfg); err != nil {
+		return err
+	}
+	if err := validation.Bot(m.decrypted(*cfg)); err != nil {
+		return err
+	}
+	return m.checkTokenConflict(ctx, *cfg)
+}
+
+// UpdateBot replaces the config of a bot. An empty or masked token keeps the
+// stored one.
 func (m *Manager) UpdateBot(ctx context.Context, cfg db.Bot) error {
+	return m.updateBot(ctx, cfg, time.Time{})
+}
+
+// UpdateBotIfMatch is UpdateBot with optimistic concurrency: it fails with
+// db.ErrStale if the stored bot's updated_at is no longer version.
+func (m *Manager) UpdateBotIfMatch(ctx context.Context, cfg db.Bot, version time.Time) error {
+	return m.updateBot(ctx, cfg, version)
+}
+
+func (m *Manager) updateBot(ctx context.Context, cfg db.Bot, version time.Time) error {
+	if err := m.prepare(ctx, &cfg); err != nil {
+		return err
+	}
+	cfg.TokenBotID = tokenBotID(m.decrypted(cfg).Token)
+	token, err := m.sealToken(cfg.Token)
+	if err != nil {
+		return err
+	}
+	cfg.Token = token
+
+	if version.IsZero() {
+		err = m.database.UpsertBot(ctx, cfg)
+	} else {
+		err = m.database.UpdateBotIfUnchanged(ctx, cfg, version)
+	}
+	if err != nil {
+		return err
+	}
+	// Keep the stored row (with its updated_at) so the lease sync does not
+	// mistake this update for a change made on another replica.
+	stored, err := m.loadBot(ctx, cfg.ID)
+	if err != nil {
+		return err
+	}
+
+	// The runner is only touched once the write went through, so a rejected
+	// update leaves a running bot alone.
 	m.mu.Lock()
 	r, exists := m.runners[cfg.ID]
 	m.mu.Unlock()
-
-	wasRunning := exists && (r.Status() == botrunner.StatusRunning || r.Status() == botrunner.StatusStarting)
+	wasRunning := exists && isActive(r.Status())
 	if wasRunning {
 		r.Stop()
 	}
 
-	if err := m.database.UpsertBot(ctx, cfg); err != nil {
-		return err
-	}
-
 	m.mu.Lock()
 	if exists {
-		r.UpdateConfig(cfg)
+		r.UpdateConfig(stored)
 	} else {
-		r = botrunner.New(cfg, m.store)
+		r = m.newRunner(stored)
 		m.runners[cfg.ID] = r
 	}
 	m.mu.Unlock()
@@ -144,6 +365,21 @@ func (m *Manager) UpdateBot(ctx context.Context, cfg db.Bot) error {
 	return nil
 }
 
+// SetEnabled changes only the enabled flag, without restarting the bot.
+func (m *Manager) SetEnabled(ctx context.Context, id string, enabled bool) error {
+	if err := m.database.SetBotEnabled(ctx, id, enabled); err != nil {
+		return err
+	}
+	stored, err := m.loadBot(ctx, id)
+	if err != nil {
+		return err
+	}
+	if r, err := m.runner(id); err == nil {
+		r.UpdateConfig(stored)
+	}
+	return nil
+}
+
 func (m *Manager) DeleteBot(ctx context.Context, id string) error {
 	m.mu.Lock()
 	r, ok := m.runners[id]
@@ -159,6 +395,7 @@ func (m *Manager) DeleteBot(ctx context.Context, id string) error {
 
 	m.mu.Lock()
 	delete(m.runners, id)
+	delete(m.owned, id)
 	m.mu.Unlock()
 	return nil
 }
@@ -168,25 +405,62 @@ func (m *Manager) Status() []BotStatusSnapshot {
 	defer m.mu.Unlock()
 
 	out := make([]BotStatusSnapshot, 0, len(m.runners))
-	for _, r := range m.runners {
-		out = append(out, BotStatusSnapshot{
+	for id, r := range m.runners {
+		snap := BotStatusSnapshot{
 			ID:        r.Cfg.ID,
 			Name:      r.Cfg.Name,
 			Type:      r.Cfg.Type,
 			Status:    r.Status(),
 			StatusMsg: r.StatusMsg(),
 			Enabled:   r.Cfg.Enabled,
-		})
+			Tags:      r.Cfg.Tags,
+		}
+		if _, ok := m.owned[id]; ok {
+			snap.Owner = m.cfg.InstanceID
+			fillRuntime(&snap, r.Stats())
+		} else if l, ok := m.leases[id]; ok && l.Alive && l.Owner != m.cfg.InstanceID {
+			// Running elsewhere: report the status the owner last renewed with.
+			snap.Owner = l.Owner
+			snap.Status = botrunner.BotStatus(l.Status)
+			snap.StatusMsg = l.StatusMsg
+		} else {
+			fillRuntime(&snap, r.Stats())
+		}
+		out = append(out, snap)
 	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
 	return out
 }
 
+func fillRuntime(snap *BotStatusSnapshot, st botrunner.Stats) {
+	var uptime int64
+	restarts := st.Restarts
+	snap.Username = st.Username
+	snap.UptimeSeconds = &uptime
+	snap.RestartCount = &restarts
+	snap.Counters = &Counters{
+		Updates:    st.Updates,
+		Callbacks:  st.Callbacks,
+		Deliveries: st.Deliveries,
+		Errors:     st.Errors,
+	}
+	if !st.StartedAt.IsZero() {
+		startedAt := st.StartedAt
+		snap.StartedAt = &startedAt
+		uptime = int64(time.Since(startedAt).Seconds())
+	}
+	if !st.LastUpdateAt.IsZero() {
+		lastUpdateAt := st.LastUpdateAt
+		snap.LastUpdateAt = &lastUpdateAt
+	}
+}
+
 func (m *Manager) Logs(id string) ([]string, error) {
 	m.mu.Lock()
 	r, ok := m.runners[id]
 	m.mu.Unlock()
 	if !ok {
-		return nil, fmt.Errorf("bot %q not found", id)
+		return nil, fmt.Errorf("bot %q %w", id, db.ErrNotFound)
 	}
 	return r.Logs.Lines(), nil
 }
@@ -0,0 +1,32 @@
+package manager
+
+import (
+	"context"
+
+	"bot-manager/internal/db"
+)
+
+// RestoreRevision rolls bot id back to the config of revision rev. The token
+// and the enabled flag are kept as they are now: snapshots don't hold the
+// token (nor the proxy password, for which the current one is used), and a
+// rollback should not start or stop the bot by itself. The
+// restore is recorded as a new revision.
+func (m *Manager) RestoreRevision(ctx context.Context, id string, rev int) (db.Bot, error) {
+	r, err := m.database.GetRevision(ctx, id, rev)
+	if err != nil {
+		return db.Bot{}, err
+	}
+	cur, err := m.database.GetBot(ctx, id)
+	if err != nil {
+		return db.Bot{}, err
+	}
+
+	cfg := r.Snapshot
+	cfg.ID = id
+	cfg.Token = "" // keep the current token
+	cfg.Enabled = cur.Enabled
+	if err := m.UpdateBot(ctx, cfg); err != nil {
+		return db.Bot{}, err
+	}
+	return m.database.GetBot(ctx, id)
+}
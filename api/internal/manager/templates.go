@@ -0,0 +1,161 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"bot-manager/internal/db"
+)
+
+// SaveTemplate stores bot botID as template t.ID, copying its messages,
+// policies, documents and welcome image. Name and Description are taken from
+// t. An existing template with the same ID is replaced.
+//
+// Each save copies the objects to a new generation prefix, so the replaced
+// template keeps its objects until the new one is stored; only then are the
+// old objects deleted.
+func (m *Manager) SaveTemplate(ctx context.Context, botID string, t db.Template) (db.Template, error) {
+	bot, err := m.database.GetBot(ctx, botID)
+	if err != nil {
+		return db.Template{}, err
+	}
+	assets, err := m.database.GetAssets(ctx, botID)
+	if err != nil {
+		return db.Template{}, err
+	}
+	old, err := m.database.GetTemplate(ctx, t.ID)
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		return db.Template{}, err
+	}
+	exists := err == nil
+
+	generation := strconv.FormatInt(time.Now().UnixNano(), 36)
+	src, dst := botPrefix(botID), templatePrefix(t.ID)+generation+"/"
+	copied, err := m.copyObjects(ctx, src, dst)
+	if err != nil {
+		return db.Template{}, err
+	}
+
+	t.Type = bot.Type
+	t.WelcomeMsg = bot.WelcomeMsg
+	t.ButtonText = bot.ButtonText
+	t.NotSubMsg = bot.NotSubMsg
+	t.SuccessMsg = bot.SuccessMsg
+	t.StaleUpdateMinutes = bot.StaleUpdateMinutes
+	t.Tags = bot.Tags
+	t.WelcomeImgKey = ""
+	if bot.WelcomeImgKey != "" {
+		t.WelcomeImgKey = rekey(bot.WelcomeImgKey, src, dst)
+	}
+	t.Assets = make([]db.TemplateAsset, 0, len(assets))
+	for _, a := range assets {
+		t.Assets = append(t.Assets, db.TemplateAsset{
+			MinioKey:    rekey(a.MinioKey, src, dst),
+			Filename:    a.Filename,
+			ContentType: a.ContentType,
+			Size:        a.Size,
+		})
+	}
+
+	if err := m.database.UpsertTemplate(ctx, t); err != nil {
+		m.deleteObjects(ctx, copied)
+		return db.Template{}, err
+	}
+	if exists {
+		if err := m.deleteGeneration(ctx, templateObjectPrefix(old)); err != nil {
+			log.Printf("manager: delete old objects of template %s: %v", t.ID, err)
+		}
+	}
+	return m.database.GetTemplate(ctx, t.ID)
+}
+
+// templateObjectPrefix is the prefix the objects of t were copied to:
+// templates/<id>/<generation>/, or templates/<id>/ for templates saved before
+// generations. Its docs/ and welcome/ hold the objects.
+func templateObjectPrefix(t db.Template) string {
+	base := templatePrefix(t.ID)
+	key := t.WelcomeImgKey
+	if key == "" && len(t.Assets) > 0 {
+		key = t.Assets[0].MinioKey
+	}
+	first, _, ok := strings.Cut(strings.TrimPrefix(key, base), "/")
+	if !strings.HasPrefix(key, base) || !ok || first == "docs" || first == "welcome" {
+		return base
+	}
+	return base + first + "/"
+}
+
+// deleteGeneration deletes the docs/ and welcome/ objects under prefix.
+func (m *Manager) deleteGeneration(ctx context.Context, prefix string) error {
+	for _, dir := range []string{"docs/", "welcome/"} {
+		objects, err := m.store.ListObjects(ctx, prefix+dir)
+		if err != nil {
+			return err
+		}
+		for _, obj := range objects {
+			if err := m.store.Delete(ctx, obj.Key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// CreateFromTemplate creates a disabled bot from template tplID; only the
+// identity in p (ID, token, channel and optionally name) is supplied.
+func (m *Manager) CreateFromTemplate(ctx context.Context, tplID string, p CloneParams) (db.Bot, error) {
+	t, err := m.database.GetTemplate(ctx, tplID)
+	if err != nil {
+		return db.Bot{}, err
+	}
+
+	cfg := db.Bot{
+		Name:               p.Name,
+		Type:               t.Type,
+		WelcomeImgKey:      t.WelcomeImgKey,
+		WelcomeMsg:         t.WelcomeMsg,
+		ButtonText:         t.ButtonText,
+		NotSubMsg:          t.NotSubMsg,
+		SuccessMsg:         t.SuccessMsg,
+		StaleUpdateMinutes: t.StaleUpdateMinutes,
+		Tags:               t.Tags,
+	}
+	if cfg.Name == "" {
+		cfg.Name = p.ID
+	}
+	assets := make([]db.Asset, 0, len(t.Assets))
+	for _, a := range t.Assets {
+		assets = append(assets, db.Asset{
+			MinioKey:    a.MinioKey,
+			Filename:    a.Filename,
+			ContentType: a.ContentType,
+			Size:        a.Size,
+		})
+	}
+	return m.createWithAssets(ctx, cfg, p, templateObjectPrefix(t), assets)
+}
+
+// DeleteTemplate removes a template and the objects of all its generations.
+func (m *Manager) DeleteTemplate(ctx context.Context, id string) error {
+	if err := m.database.DeleteTemplate(ctx, id); err != nil {
+		return err
+	}
+	return m.deleteTemplateObjects(ctx, id)
+}
+
+func (m *Manager) deleteTemplateObjects(ctx context.Context, id string) error {
+	objects, err := m.store.ListObjects(ctx, templatePrefix(id))
+	if err != nil {
+		return err
+	}
+	for _, obj := range objects {
+		if err := m.store.Delete(ctx, obj.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package manager
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"strings"
+
+	"bot-manager/internal/db"
+	"bot-manager/internal/secrets"
+)
+
+// Tokens are stored encrypted (see secrets) and decrypted only here: runners
+// get configs with the plaintext token, everything read back from the
+// database carries the ciphertext.
+
+// loadBot reads a bot from the database with its token decrypted.
+func (m *Manager) loadBot(ctx context.Context, id string) (db.Bot, error) {
+	b, err := m.database.GetBot(ctx, id)
+	if err != nil {
+		return b, err
+	}
+	return m.decrypted(b), nil
+}
+
+// decrypted returns b with its token in plaintext. A token that can't be
+// decrypted is cleared, so the bot fails to authorize instead of sending the
+// ciphertext to Telegram.
+func (m *Manager) decrypted(b db.Bot) db.Bot {
+	token, err := m.cfg.Tokens.Decrypt(b.Token)
+	if err != nil {
+		log.Printf("manager: %s: token: %v", b.ID, err)
+		token = ""
+	}
+	b.Token = token
+	return b
+}
+
+// sealToken returns token in its stored form: encrypted with the current key.
+// Already encrypted tokens (imports, restores) are accepted if they decrypt.
+func (m *Manager) sealToken(token string) (string, error) {
+	plain, err := m.cfg.Tokens.Decrypt(token)
+	if err != nil {
+		return "", err
+	}
+	if secrets.IsEncrypted(token) && !m.cfg.Tokens.NeedsRotation(token) {
+		return token, nil
+	}
+	return m.cfg.Tokens.Encrypt(plain)
+}
+
+// maskMarker appears in masked tokens; a token containing it is never a real
+// one, so updates treat it as "unchanged".
+const maskMarker = "****"
+
+// maskToken hides the secret part of a token: "123456:ABC…wxyz" → "123456:****wxyz".
+func maskToken(plain string) string {
+	if plain == "" {
+		return ""
+	}
+	id, secret, _ := strings.Cut(plain, ":")
+	tail := ""
+	if len(secret) > 8 {
+		tail = secret[len(secret)-4:]
+	}
+	return id + ":" + maskMarker + tail
+}
+
+// keepsToken reports whether a token sent on update means "leave the stored
+// token as it is": omitted, or the masked value handed out by the API.
+func keepsToken(token string) bool {
+	return token == "" || strings.Contains(token, maskMarker)
+}
+
+// Redact returns b with its token and proxy password masked, for API
+// responses.
+func (m *Manager) Redact(b db.Bot) db.Bot {
+	b.Token = maskToken(m.decrypted(b).Token)
+	b.Proxy = db.RedactProxy(b.Proxy)
+	return b
+}
+
+// resolveProxy puts the stored password back into a proxy URL that carries
+// the masked one from Redact, so clients can send a config back unchanged.
+// The password is only restored for the same proxy and user; otherwise the
+// mask stays and fails validation.
+func (m *Manager) resolveProxy(ctx context.Context, cfg *db.Bot) {
+	u, err := url.Parse(cfg.Proxy)
+	if err != nil || u.User == nil {
+		return
+	}
+	if pw, _ := u.User.Password(); pw != db.ProxyMask {
+		return
+	}
+	cur, err := m.database.GetBot(ctx, cfg.ID)
+	if err != nil {
+		return
+	}
+	old, err := url.Parse(cur.Proxy)
+	if err != nil || old.User == nil {
+		return
+	}
+	if old.Scheme != u.Scheme || old.Host != u.Host || old.User.Username() != u.User.Username() {
+		return
+	}
+	pw, _ := old.User.Password()
+	u.User = url.UserPassword(u.User.Username(), pw)
+	cfg.Proxy = u.String()
+}
+
+// PlainToken returns the decrypted token of b. Callers handing it out are
+// expected to audit that.
+func (m *Manager) PlainToken(b db.Bot) (string, error) {
+	return m.cfg.Tokens.Decrypt(b.Token)
+}
+
+// EncryptTokens encrypts plaintext tokens and re-encrypts tokens sealed with
+// a retired key. It returns the number of rows rewritten.
+func (m *Manager) EncryptTokens(ctx context.Context) (int, error) {
+	if m.cfg.Tokens == nil {
+		return 0, nil
+	}
+	bots, err := m.database.GetAllBots(ctx)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, b := range bots {
+		if !m.cfg.Tokens.NeedsRotation(b.Token) {
+			continue
+		}
+		sealed, err := m.sealToken(b.Token)
+		if err != nil {
+			log.Printf("manager: %s: encrypt token: %v", b.ID, err)
+			continue
+		}
+		ok, err := m.database.ReplaceBotToken(ctx, b.ID, b.Token, sealed)
+		if err != nil {
+			return n, err
+		}
+		if ok {
+			n++
+		}
+	}
+	return n, nil
+}
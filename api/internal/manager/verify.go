@@ -0,0 +1,39 @@
+package manager
+
+import (
+	"context"
+	"time"
+
+	"bot-manager/internal/botrunner"
+	"bot-manager/internal/db"
+)
+
+const verifyTimeout = 15 * time.Second
+
+// Verify checks the token and channel of cfg against Telegram. For an
+// existing bot, an empty or masked token and a zero channel fall back to the
+// stored values, as does a masked proxy password. cfg's API endpoint and
+// proxy are otherwise used as given, since "" is a valid setting (the global
+// default); the stored token is only used with the stored endpoint and proxy.
+func (m *Manager) Verify(ctx context.Context, cfg db.Bot) botrunner.VerifyResult {
+	if cfg.ID != "" {
+		m.resolveProxy(ctx, &cfg)
+		if err := m.resolveToken(ctx, &cfg); err != nil {
+			return botrunner.VerifyResult{MissingRights: []string{}, Errors: []string{err.Error()}}
+		}
+		if cfg.ChannelID == 0 {
+			if cur, err := m.database.GetBot(ctx, cfg.ID); err == nil {
+				cfg.ChannelID = cur.ChannelID
+			}
+		}
+	}
+	cfg = m.decrypted(cfg)
+
+	ctx, cancel := context.WithTimeout(ctx, verifyTimeout)
+	defer cancel()
+	return botrunner.Verify(ctx, botrunner.VerifyParams{
+		Token:      cfg.Token,
+		ChannelID:  cfg.ChannelID,
+		Connection: m.cfg.Telegram.For(cfg),
+	})
+}
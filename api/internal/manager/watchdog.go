@@ -0,0 +1,126 @@
+package manager
+
+// watchdog.go — detection of bots that are "running" but stuck.
+// The polling loop and the outbox report progress through the runner's
+// Heartbeat; a bot whose getUpdates has not returned, or whose update handler
+// or delivery has been busy, for longer than the thresholds is flagged as
+// degraded and, if configured, restarted.
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"bot-manager/internal/botrunner"
+)
+
+type WatchdogConfig struct {
+	// Interval between checks; 0 disables the watchdog.
+	Interval time.Duration
+	// PollStall is the longest acceptable gap between completed getUpdates
+	// calls. It must exceed the 60s long-poll timeout.
+	PollStall time.Duration
+	// HandlerStall is the longest acceptable time for handling one update or
+	// delivering one outbox item.
+	HandlerStall time.Duration
+	// AutoRestart restarts degraded bots instead of only flagging them.
+	AutoRestart bool
+}
+
+type watchdog struct {
+	cfg        WatchdogConfig
+	mu         sync.Mutex
+	restarting map[string]bool
+}
+
+func newWatchdog(cfg WatchdogConfig) *watchdog {
+	return &watchdog{cfg: cfg, restarting: make(map[string]bool)}
+}
+
+// diagnose returns why a bot looks stuck, or "" if it is healthy.
+func (w *watchdog) diagnose(hb botrunner.Heartbeat, now time.Time) string {
+	if !hb.HandlerStartedAt.IsZero() && now.Sub(hb.HandlerStartedAt) > w.cfg.HandlerStall {
+		return fmt.Sprintf("watchdog: update handler busy for %s", now.Sub(hb.HandlerStartedAt).Round(time.Second))
+	}
+	if !hb.SendStartedAt.IsZero() && now.Sub(hb.SendStartedAt) > w.cfg.HandlerStall {
+		return fmt.Sprintf("watchdog: message delivery busy for %s", now.Sub(hb.SendStartedAt).Round(time.Second))
+	}
+	if !hb.LastPollAt.IsZero() && now.Sub(hb.LastPollAt) > w.cfg.PollStall {
+		return fmt.Sprintf("watchdog: no getUpdates response for %s", now.Sub(hb.LastPollAt).Round(time.Second))
+	}
+	return ""
+}
+
+func (m *Manager) watchdogLoop(ctx context.Context) {
+	t := time.NewTicker(m.cfg.Watchdog.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			m.checkStuck()
+		}
+	}
+}
+
+// checkStuck runs one watchdog pass over the bots running on this replica.
+func (m *Manager) checkStuck() {
+	m.mu.Lock()
+	runners := make([]*botrunner.BotRunner, 0, len(m.owned))
+	for id := range m.owned {
+		if r, ok := m.runners[id]; ok {
+			runners = append(runners, r)
+		}
+	}
+	m.mu.Unlock()
+
+	w := m.watchdog
+	now := time.Now()
+	for _, r := range runners {
+		status := r.Status()
+		if status != botrunner.StatusRunning && status != botrunner.StatusDegraded {
+			continue
+		}
+		reason := w.diagnose(r.Heartbeat(), now)
+		r.SetDegraded(reason)
+		if reason == "" {
+			continue
+		}
+		if status == botrunner.StatusRunning {
+			log.Printf("manager: %s degraded: %s", r.Cfg.ID, reason)
+			r.Logf("%s", reason)
+		}
+		if w.cfg.AutoRestart {
+			m.watchdogRestart(r, reason)
+		}
+	}
+}
+
+func (m *Manager) watchdogRestart(r *botrunner.BotRunner, reason string) {
+	id := r.Cfg.ID
+	w := m.watchdog
+	w.mu.Lock()
+	if w.restarting[id] {
+		w.mu.Unlock()
+		return
+	}
+	w.restarting[id] = true
+	w.mu.Unlock()
+
+	log.Printf("manager: watchdog restarting %s (%s)", id, reason)
+	r.Logf("Перезапуск watchdog: %s", reason)
+	go func() {
+		defer func() {
+			w.mu.Lock()
+			delete(w.restarting, id)
+			w.mu.Unlock()
+		}()
+		r.Stop()
+		if err := r.Start(); err != nil {
+			log.Printf("manager: watchdog start %s: %v", id, err)
+		}
+	}()
+}
@@ -0,0 +1,82 @@
+package manager
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"bot-manager/internal/botrunner"
+	"bot-manager/internal/db"
+	"bot-manager/internal/tgtest"
+)
+
+func TestWatchdogDiagnose(t *testing.T) {
+	w := newWatchdog(WatchdogConfig{PollStall: 90 * time.Second, HandlerStall: time.Minute})
+	now := time.Now()
+	ago := func(d time.Duration) time.Time { return now.Add(-d) }
+	for _, tc := range []struct {
+		name string
+		hb   botrunner.Heartbeat
+		want string // prefix of the reason; "" for healthy
+	}{
+		{"not started", botrunner.Heartbeat{}, ""},
+		{"polling", botrunner.Heartbeat{LastPollAt: ago(time.Minute)}, ""},
+		{"poll stalled", botrunner.Heartbeat{LastPollAt: ago(2 * time.Minute)}, "watchdog: no getUpdates response for 2m0s"},
+		{"handler busy", botrunner.Heartbeat{LastPollAt: ago(time.Second), HandlerStartedAt: ago(30 * time.Second)}, ""},
+		{"handler stalled", botrunner.Heartbeat{LastPollAt: ago(time.Second), HandlerStartedAt: ago(2 * time.Minute)}, "watchdog: update handler busy"},
+		{"delivery stalled", botrunner.Heartbeat{LastPollAt: ago(time.Second), SendStartedAt: ago(2 * time.Minute)}, "watchdog: message delivery busy"},
+		// A stuck handler blocks the poll loop too; the handler is the cause.
+		{"handler before poll", botrunner.Heartbeat{LastPollAt: ago(3 * time.Minute), HandlerStartedAt: ago(2 * time.Minute)}, "watchdog: update handler busy"},
+	} {
+		got := w.diagnose(tc.hb, now)
+		if (tc.want == "") != (got == "") || !strings.HasPrefix(got, tc.want) {
+			t.Errorf("%s: diagnose = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCheckStuck(t *testing.T) {
+	tg := tgtest.NewServer(testToken)
+	defer tg.Close()
+	ctx := context.Background()
+	m := newReplica(t, db.NewMemStore(), tg, "a", time.Minute)
+	// The fake server holds each long poll for up to two seconds, longer than
+	// this threshold.
+	m.watchdog = newWatchdog(WatchdogConfig{PollStall: 100 * time.Millisecond, HandlerStall: time.Minute})
+
+	cfg := testBot("gate", testToken)
+	cfg.Proxy, cfg.Enabled = "", true
+	if err := m.AddBot(ctx, cfg); err != nil {
+		t.Fatal(err)
+	}
+	m.syncLeases(ctx)
+	waitStatus(t, m, "gate", botrunner.StatusRunning)
+	r, _ := m.runner("gate")
+
+	time.Sleep(200 * time.Millisecond)
+	m.checkStuck()
+	if r.Status() != botrunner.StatusDegraded || !strings.Contains(r.StatusMsg(), "no getUpdates response") {
+		t.Fatalf("after a stalled poll: %s %q", r.Status(), r.StatusMsg())
+	}
+
+	// The flag clears once the bot looks healthy again.
+	m.watchdog.cfg.PollStall = time.Minute
+	m.checkStuck()
+	if r.Status() != botrunner.StatusRunning || r.StatusMsg() != "" {
+		t.Fatalf("after recovery: %s %q", r.Status(), r.StatusMsg())
+	}
+
+	// With AutoRestart a stuck bot is restarted.
+	m.watchdog.cfg.PollStall, m.watchdog.cfg.AutoRestart = 100*time.Millisecond, true
+	startedAt := r.Stats().StartedAt
+	time.Sleep(200 * time.Millisecond)
+	m.checkStuck()
+	deadline := time.Now().Add(5 * time.Second)
+	for !r.Stats().StartedAt.After(startedAt) || r.Status() != botrunner.StatusRunning {
+		if time.Now().After(deadline) {
+			t.Fatalf("bot not restarted: %s, started at %v", r.Status(), r.Stats().StartedAt)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
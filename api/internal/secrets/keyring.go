@@ -0,0 +1,125 @@
+// Package secrets encrypts bot tokens at rest with AES-256-GCM.
+//
+// Encrypted values look like "enc:v1:<key id>:<base64 nonce+ciphertext>".
+// A keyring holds one current key used for encryption and any number of older
+// keys that are still accepted for decryption, so keys can be rotated by
+// prepending a new one and re-encrypting.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const prefix = "enc:v1:"
+
+// ErrNoKey is returned when decrypting a value whose key is not in the keyring.
+var ErrNoKey = errors.New("encryption key not configured")
+
+type Keyring struct {
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+// ParseKeyring parses a comma-separated list of "<id>:<base64 32-byte key>"
+// entries. The first entry is the current key. An empty spec yields a nil
+// keyring, which leaves values in plaintext.
+func ParseKeyring(spec string) (*Keyring, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+	k := &Keyring{keys: make(map[string]cipher.AEAD)}
+	for _, entry := range strings.Split(spec, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("key entry %q: want <id>:<base64 key>", entry)
+		}
+		if _, dup := k.keys[id]; dup {
+			return nil, fmt.Errorf("key %q: duplicate id", id)
+		}
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		if len(raw) != 32 {
+			return nil, fmt.Errorf("key %q: must be 32 bytes, got %d", id, len(raw))
+		}
+		block, err := aes.NewCipher(raw)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		if k.current == "" {
+			k.current = id
+		}
+		k.keys[id] = aead
+	}
+	return k, nil
+}
+
+// IsEncrypted reports whether v is in the encrypted format.
+func IsEncrypted(v string) bool {
+	return strings.HasPrefix(v, prefix)
+}
+
+// Encrypt encrypts plain with the current key. A nil keyring returns plain
+// unchanged.
+func (k *Keyring) Encrypt(plain string) (string, error) {
+	if k == nil || plain == "" {
+		return plain, nil
+	}
+	aead := k.keys[k.current]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plain), []byte(k.current))
+	return prefix + k.current + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt returns the plaintext of v. Values that are not encrypted are
+// returned as they are.
+func (k *Keyring) Decrypt(v string) (string, error) {
+	if !IsEncrypted(v) {
+		return v, nil
+	}
+	id, encoded, ok := strings.Cut(strings.TrimPrefix(v, prefix), ":")
+	if !ok {
+		return "", errors.New("malformed encrypted value")
+	}
+	if k == nil {
+		return "", fmt.Errorf("%w: %q", ErrNoKey, id)
+	}
+	aead, ok := k.keys[id]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrNoKey, id)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", errors.New("malformed encrypted value")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, []byte(id))
+	if err != nil {
+		return "", fmt.Errorf("decrypt with key %q: %w", id, err)
+	}
+	return string(plain), nil
+}
+
+// NeedsRotation reports whether v should be re-encrypted: it is plaintext or
+// encrypted with a key other than the current one.
+func (k *Keyring) NeedsRotation(v string) bool {
+	if k == nil || v == "" {
+		return false
+	}
+	return !strings.HasPrefix(v, prefix+k.current+":")
+}
@@ -0,0 +1,151 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func key(b byte) string {
+	raw := make([]byte, 32)
+	for i := range raw {
+		raw[i] = b
+	}
+	return base64.StdEncoding.EncodeToString(raw)
+}
+
+func mustKeyring(t *testing.T, spec string) *Keyring {
+	t.Helper()
+	k, err := ParseKeyring(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestParseKeyring(t *testing.T) {
+	for _, tc := range []struct {
+		spec string
+		ok   bool
+	}{
+		{"", true},
+		{"k1:" + key(1), true},
+		{"k2:" + key(2) + ", k1:" + key(1), true},
+		{"k1", false},
+		{":" + key(1), false},
+		{"k1:not base64!", false},
+		{"k1:" + base64.StdEncoding.EncodeToString([]byte("short")), false},
+		{"k1:" + key(1) + ",k1:" + key(2), false},
+	} {
+		_, err := ParseKeyring(tc.spec)
+		if (err == nil) != tc.ok {
+			t.Errorf("ParseKeyring(%q) = %v, want ok=%v", tc.spec, err, tc.ok)
+		}
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	k := mustKeyring(t, "k1:"+key(1))
+	for _, plain := range []string{"123456789:AAbbcc", "ünïcode", strings.Repeat("x", 1000)} {
+		enc, err := k.Encrypt(plain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !IsEncrypted(enc) || !strings.HasPrefix(enc, "enc:v1:k1:") || strings.Contains(enc, plain) {
+			t.Errorf("Encrypt(%q) = %q", plain, enc)
+		}
+		if got, err := k.Decrypt(enc); err != nil || got != plain {
+			t.Errorf("Decrypt(Encrypt(%q)) = %q, %v", plain, got, err)
+		}
+		if k.NeedsRotation(enc) {
+			t.Errorf("%q needs rotation", enc)
+		}
+	}
+
+	// Nonces are random, so equal tokens do not give equal ciphertexts.
+	a, _ := k.Encrypt("same")
+	b, _ := k.Encrypt("same")
+	if a == b {
+		t.Error("two encryptions are equal")
+	}
+	if enc, _ := k.Encrypt(""); enc != "" {
+		t.Errorf("Encrypt(\"\") = %q", enc)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	old := mustKeyring(t, "k1:"+key(1))
+	enc, _ := old.Encrypt("token")
+
+	// k2 is prepended: new values use it, k1 values still decrypt.
+	rotated := mustKeyring(t, "k2:"+key(2)+",k1:"+key(1))
+	if got, err := rotated.Decrypt(enc); err != nil || got != "token" {
+		t.Errorf("Decrypt of a k1 value = %q, %v", got, err)
+	}
+	if !rotated.NeedsRotation(enc) || !rotated.NeedsRotation("plain") {
+		t.Error("k1 and plaintext values do not need rotation")
+	}
+	reenc, _ := rotated.Encrypt("token")
+	if !strings.HasPrefix(reenc, "enc:v1:k2:") || rotated.NeedsRotation(reenc) {
+		t.Errorf("re-encrypted = %q", reenc)
+	}
+
+	// Once k1 is dropped, its values fail with ErrNoKey.
+	if _, err := mustKeyring(t, "k2:"+key(2)).Decrypt(enc); !errors.Is(err, ErrNoKey) {
+		t.Errorf("Decrypt without k1 = %v, want ErrNoKey", err)
+	}
+}
+
+func TestDecryptErrors(t *testing.T) {
+	k := mustKeyring(t, "k1:"+key(1))
+	enc, _ := k.Encrypt("token")
+	sealed, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(enc, "enc:v1:k1:"))
+	sealed[len(sealed)-1] ^= 1
+	tampered := "enc:v1:k1:" + base64.StdEncoding.EncodeToString(sealed)
+	// Same ciphertext claimed under another key ID: the ID is authenticated.
+	relabelled := mustKeyring(t, "k1:"+key(1)+",k9:"+key(1))
+
+	for _, tc := range []struct {
+		name  string
+		k     *Keyring
+		value string
+		noKey bool
+	}{
+		{"unknown key", k, "enc:v1:k7:" + strings.TrimPrefix(enc, "enc:v1:k1:"), true},
+		{"nil keyring", nil, enc, true},
+		{"tampered ciphertext", k, tampered, false},
+		{"relabelled key", relabelled, "enc:v1:k9:" + strings.TrimPrefix(enc, "enc:v1:k1:"), false},
+		{"no key id", k, "enc:v1:garbage", false},
+		{"bad base64", k, "enc:v1:k1:not base64!", false},
+		{"too short", k, "enc:v1:k1:" + base64.StdEncoding.EncodeToString([]byte("abc")), false},
+	} {
+		got, err := tc.k.Decrypt(tc.value)
+		if err == nil {
+			t.Errorf("%s: Decrypt = %q, want an error", tc.name, got)
+			continue
+		}
+		if errors.Is(err, ErrNoKey) != tc.noKey {
+			t.Errorf("%s: Decrypt = %v, ErrNoKey %v", tc.name, err, tc.noKey)
+		}
+	}
+}
+
+func TestPlaintextPassthrough(t *testing.T) {
+	var k *Keyring
+	for _, v := range []string{"", "123456789:AAbbcc", "enc:v2:something"} {
+		if enc, err := k.Encrypt(v); err != nil || enc != v {
+			t.Errorf("nil Encrypt(%q) = %q, %v", v, enc, err)
+		}
+		if got, err := k.Decrypt(v); err != nil || got != v {
+			t.Errorf("nil Decrypt(%q) = %q, %v", v, got, err)
+		}
+		if k.NeedsRotation(v) {
+			t.Errorf("nil keyring wants to rotate %q", v)
+		}
+	}
+	// A keyring also passes through values not in the enc:v1: format.
+	if got, err := mustKeyring(t, "k1:"+key(1)).Decrypt("123456789:AAbbcc"); err != nil || got != "123456789:AAbbcc" {
+		t.Errorf("Decrypt of plaintext = %q, %v", got, err)
+	}
+}
@@ -89,3 +89,12 @@ func (s *MinioStore) ListObjects(ctx context.Context, prefix string) ([]minio.Ob
 	}
 	return objects, nil
 }
+
+// CopyObject copies src to dst inside the bucket without downloading it.
+func (s *MinioStore) CopyObject(ctx context.Context, src, dst string) error {
+	_, err := s.client.CopyObject(ctx,
+		minio.CopyDestOptions{Bucket: s.bucket, Object: dst},
+		minio.CopySrcOptions{Bucket: s.bucket, Object: src},
+	)
+	return err
+}
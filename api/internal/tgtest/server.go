@@ -0,0 +1,402 @@
+// Package tgtest provides a fake Telegram Bot API server for tests.
+//
+// Point a bot at Server.URL as its API endpoint, script incoming updates with
+// PushUpdate, SendText and PressButton, control what getChatMember answers
+// with SetMember, make calls fail with Fail and FloodWait, and inspect what
+// the bot sent with Calls and WaitCalls.
+package tgtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maxPoll caps how long getUpdates waits for an update, whatever timeout the
+// client asks for, so tests never hang on a long poll.
+const maxPoll = 2 * time.Second
+
+// Call is one request the bot made.
+type Call struct {
+	Method string
+	Params url.Values
+	Files  map[string]File // multipart uploads by field name ("photo", "document")
+}
+
+// File is an uploaded file.
+type File struct {
+	Name string
+	Data []byte
+}
+
+// ChatID returns the chat_id parameter of the call.
+func (c Call) ChatID() int64 {
+	id, _ := strconv.ParseInt(c.Params.Get("chat_id"), 10, 64)
+	return id
+}
+
+type memberKey struct{ chat, user int64 }
+
+type failure struct {
+	code        int
+	description string
+	retryAfter  int
+	times       int
+}
+
+// Server is a fake Bot API server for a single bot token.
+type Server struct {
+	*httptest.Server
+	Token string
+	Bot   tgbotapi.User
+
+	mu       sync.Mutex
+	updates  []tgbotapi.Update
+	nextUpd  int
+	nextMsg  int
+	calls    []Call
+	changed  chan struct{} // closed and replaced whenever updates or calls change
+	members  map[memberKey]tgbotapi.ChatMember
+	chats    map[int64]tgbotapi.Chat
+	failures map[string]*failure
+}
+
+// NewServer starts a fake Bot API server that accepts token. Close it when
+// done.
+func NewServer(token string) *Server {
+	id, _ := strconv.ParseInt(strings.SplitN(token, ":", 2)[0], 10, 64)
+	s := &Server{
+		Token:    token,
+		Bot:      tgbotapi.User{ID: id, IsBot: true, FirstName: "Test Bot", UserName: "test_bot"},
+		nextUpd:  1,
+		nextMsg:  1,
+		changed:  make(chan struct{}),
+		members:  make(map[memberKey]tgbotapi.ChatMember),
+		chats:    make(map[int64]tgbotapi.Chat),
+		failures: make(map[string]*failure),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// notify wakes everyone waiting for a change. Callers hold s.mu.
+func (s *Server) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// PushUpdate queues an update for getUpdates, assigning the next update ID
+// if it has none, and returns the ID.
+func (s *Server) PushUpdate(u tgbotapi.Update) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if u.UpdateID == 0 {
+		u.UpdateID = s.nextUpd
+	}
+	s.nextUpd = u.UpdateID + 1
+	s.updates = append(s.updates, u)
+	s.notify()
+	return u.UpdateID
+}
+
+// SendText queues a private text message from user userID; a text starting
+// with "/" is sent as a bot command.
+func (s *Server) SendText(userID int64, text string) int {
+	msg := s.message(userID)
+	msg.Text = text
+	if strings.HasPrefix(text, "/") {
+		cmd, _, _ := strings.Cut(text, " ")
+		msg.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(cmd)}}
+	}
+	return s.PushUpdate(tgbotapi.Update{Message: msg})
+}
+
+// PressButton queues a callback query with data from user userID, as if
+// they pressed an inline button under a message in their private chat.
+func (s *Server) PressButton(userID int64, data string) int {
+	return s.PushUpdate(tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:      fmt.Sprintf("cb%d", time.Now().UnixNano()),
+		From:    &tgbotapi.User{ID: userID, FirstName: "User"},
+		Message: s.message(userID),
+		Data:    data,
+	}})
+}
+
+func (s *Server) message(userID int64) *tgbotapi.Message {
+	s.mu.Lock()
+	id := s.nextMsg
+	s.nextMsg++
+	s.mu.Unlock()
+	return &tgbotapi.Message{
+		MessageID: id,
+		From:      &tgbotapi.User{ID: userID, FirstName: "User"},
+		Date:      int(time.Now().Unix()),
+		Chat:      &tgbotapi.Chat{ID: userID, Type: "private"},
+	}
+}
+
+// SetMember sets what getChatMember answers for userID in chatID, e.g.
+// "member", "left" or "administrator". Unknown users are "left". An
+// administrator has all rights; use SetChatMember for fewer.
+func (s *Server) SetMember(chatID, userID int64, status string) {
+	m := tgbotapi.ChatMember{User: &tgbotapi.User{ID: userID}, Status: status}
+	if status == "administrator" {
+		m.CanManageChat, m.CanPostMessages, m.CanEditMessages, m.CanDeleteMessages = true, true, true, true
+		m.CanRestrictMembers, m.CanPromoteMembers, m.CanChangeInfo, m.CanInviteUsers = true, true, true, true
+	}
+	s.SetChatMember(chatID, m)
+}
+
+// SetChatMember sets what getChatMember answers for m.User in chatID.
+func (s *Server) SetChatMember(chatID int64, m tgbotapi.ChatMember) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.members[memberKey{chatID, m.User.ID}] = m
+}
+
+// SetChat makes getChat know chat, by ID and by @username.
+func (s *Server) SetChat(chat tgbotapi.Chat) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chats[chat.ID] = chat
+}
+
+// Fail makes the next times calls of method fail with the given error code
+// and description.
+func (s *Server) Fail(method string, code int, description string, times int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[method] = &failure{code: code, description: description, times: times}
+}
+
+// FloodWait makes the next times calls of method fail with 429 Too Many
+// Requests, asking the client to retry after the given number of seconds.
+func (s *Server) FloodWait(method string, retryAfter, times int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[method] = &failure{code: http.StatusTooManyRequests,
+		description: fmt.Sprintf("Too Many Requests: retry after %d", retryAfter),
+		retryAfter:  retryAfter, times: times}
+}
+
+// Calls returns the recorded calls of the given methods, or all calls if
+// none are given. getUpdates is not recorded.
+func (s *Server) Calls(methods ...string) []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.callsLocked(methods)
+}
+
+func (s *Server) callsLocked(methods []string) []Call {
+	var out []Call
+	for _, c := range s.calls {
+		if len(methods) == 0 || slices.Contains(methods, c.Method) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// WaitCalls waits until at least n calls of the given methods were recorded
+// and returns all of them, or fails after timeout.
+func (s *Server) WaitCalls(timeout time.Duration, n int, methods ...string) ([]Call, error) {
+	deadline := time.After(timeout)
+	for {
+		s.mu.Lock()
+		calls := s.callsLocked(methods)
+		changed := s.changed
+		s.mu.Unlock()
+		if len(calls) >= n {
+			return calls, nil
+		}
+		select {
+		case <-changed:
+		case <-deadline:
+			return calls, fmt.Errorf("got %d of %d calls to %v within %s", len(calls), n, methods, timeout)
+		}
+	}
+}
+
+// Pending returns the number of queued updates with an ID of at least
+// offset, i.e. not yet confirmed by the bot.
+func (s *Server) Pending(offset int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pendingLocked(offset))
+}
+
+func (s *Server) pendingLocked(offset int) []tgbotapi.Update {
+	var out []tgbotapi.Update
+	for _, u := range s.updates {
+		if u.UpdateID >= offset {
+			out = append(out, u)
+		}
+	}
+	return out
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	method, ok := strings.CutPrefix(r.URL.Path, "/bot"+s.Token+"/")
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	call := Call{Method: method, Files: map[string]File{}}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			writeError(w, http.StatusBadRequest, "Bad Request: "+err.Error())
+			return
+		}
+		for field, headers := range r.MultipartForm.File {
+			f, err := headers[0].Open()
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "Bad Request: "+err.Error())
+				return
+			}
+			data, _ := io.ReadAll(f)
+			f.Close()
+			call.Files[field] = File{Name: headers[0].Filename, Data: data}
+		}
+	} else if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, "Bad Request: "+err.Error())
+		return
+	}
+	call.Params = r.Form
+
+	s.mu.Lock()
+	if method != "getUpdates" {
+		s.calls = append(s.calls, call)
+		s.notify()
+	}
+	f := s.failures[method]
+	if f != nil && f.times > 0 {
+		f.times--
+		s.mu.Unlock()
+		writeFailure(w, f)
+		return
+	}
+	s.mu.Unlock()
+
+	switch method {
+	case "getUpdates":
+		s.getUpdates(w, r, call.Params)
+	case "getMe":
+		writeResult(w, s.Bot)
+	case "sendMessage", "sendPhoto", "sendDocument":
+		writeResult(w, s.sent(call))
+	case "answerCallbackQuery":
+		writeResult(w, true)
+	case "getChatMember":
+		s.getChatMember(w, call.Params)
+	case "getChat":
+		s.getChat(w, call.Params)
+	default:
+		writeError(w, http.StatusNotFound, "Not Found: method not found")
+	}
+}
+
+func (s *Server) getUpdates(w http.ResponseWriter, r *http.Request, p url.Values) {
+	offset, _ := strconv.Atoi(p.Get("offset"))
+	timeout, _ := strconv.Atoi(p.Get("timeout"))
+	wait := min(time.Duration(timeout)*time.Second, maxPoll)
+	deadline := time.After(wait)
+	for {
+		s.mu.Lock()
+		// Like Telegram, forget updates older than the requested offset.
+		if offset > 0 {
+			s.updates = s.pendingLocked(offset)
+		}
+		updates := s.pendingLocked(offset)
+		changed := s.changed
+		s.mu.Unlock()
+		if len(updates) > 0 {
+			writeResult(w, updates)
+			return
+		}
+		select {
+		case <-changed:
+		case <-deadline:
+			writeResult(w, []tgbotapi.Update{})
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *Server) sent(call Call) tgbotapi.Message {
+	s.mu.Lock()
+	id := s.nextMsg
+	s.nextMsg++
+	s.mu.Unlock()
+	return tgbotapi.Message{
+		MessageID: id,
+		From:      &s.Bot,
+		Date:      int(time.Now().Unix()),
+		Chat:      &tgbotapi.Chat{ID: call.ChatID(), Type: "private"},
+		Text:      call.Params.Get("text"),
+		Caption:   call.Params.Get("caption"),
+	}
+}
+
+func (s *Server) getChatMember(w http.ResponseWriter, p url.Values) {
+	chatID, _ := strconv.ParseInt(p.Get("chat_id"), 10, 64)
+	userID, _ := strconv.ParseInt(p.Get("user_id"), 10, 64)
+	s.mu.Lock()
+	m, ok := s.members[memberKey{chatID, userID}]
+	s.mu.Unlock()
+	if !ok {
+		m = tgbotapi.ChatMember{User: &tgbotapi.User{ID: userID}, Status: "left"}
+	}
+	writeResult(w, m)
+}
+
+func (s *Server) getChat(w http.ResponseWriter, p url.Values) {
+	ref := p.Get("chat_id")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.chats {
+		if strconv.FormatInt(c.ID, 10) == ref || (c.UserName != "" && "@"+c.UserName == ref) {
+			writeResult(w, c)
+			return
+		}
+	}
+	writeError(w, http.StatusBadRequest, "Bad Request: chat not found")
+}
+
+func writeResult(w http.ResponseWriter, result interface{}) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tgbotapi.APIResponse{Ok: true, Result: data})
+}
+
+func writeFailure(w http.ResponseWriter, f *failure) {
+	resp := tgbotapi.APIResponse{Ok: false, ErrorCode: f.code, Description: f.description}
+	if f.retryAfter > 0 {
+		resp.Parameters = &tgbotapi.ResponseParameters{RetryAfter: f.retryAfter}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(f.code)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func writeError(w http.ResponseWriter, code int, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(tgbotapi.APIResponse{Ok: false, ErrorCode: code, Description: description})
+}
@@ -0,0 +1,200 @@
+// Package validation checks bot configs and user accounts before they are
+// saved, so mistakes surface as field-level errors instead of failures inside
+// Telegram.
+package validation
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"bot-manager/internal/botrunner"
+	"bot-manager/internal/db"
+)
+
+// Error codes.
+const (
+	CodeRequired   = "required"
+	CodeFormat     = "invalid_format"
+	CodeRange      = "out_of_range"
+	CodeTooLong    = "too_long"
+	CodeMarkdown   = "invalid_markdown"
+	CodeInvalidURL = "invalid_url"
+)
+
+// Telegram limits, in characters.
+const (
+	MaxMessageLength = 4096
+	MaxCaptionLength = 1024
+	MaxButtonLength  = 64
+	MaxNameLength    = 128
+	MaxTagLength     = 32
+)
+
+// FieldError describes one invalid field; Field is the JSON field name.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Errors is a list of field errors. It is returned as an error value.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	parts := make([]string, len(e))
+	for i, fe := range e {
+		parts[i] = fe.Field + ": " + fe.Message
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+func (e *Errors) add(field, code, format string, args ...interface{}) {
+	*e = append(*e, FieldError{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+var (
+	// IDs end up in URL paths and MinIO prefixes, so no slashes or dots.
+	idRe    = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+	tokenRe = regexp.MustCompile(`^[0-9]{5,20}:[A-Za-z0-9_-]{30,60}$`)
+	tagRe   = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]*$`)
+)
+
+// Channel and supergroup IDs are -100 followed by the 10–13 digit chat ID.
+const (
+	minChannelID = -1999999999999
+	maxChannelID = -1000000000000
+)
+
+// reservedIDs are top-level MinIO prefixes other than bot directories. A bot
+// with such an ID would share its objects with them.
+var reservedIDs = map[string]bool{"templates": true}
+
+// ValidID reports whether id is usable as a bot or template ID.
+func ValidID(id string) bool {
+	return idRe.MatchString(id) && !reservedIDs[id]
+}
+
+// Bot validates a bot config with its token in plaintext. It returns nil or
+// an Errors value.
+func Bot(b db.Bot) error {
+	var errs Errors
+
+	switch {
+	case b.ID == "":
+		errs.add("id", CodeRequired, "id is required")
+	case !idRe.MatchString(b.ID):
+		errs.add("id", CodeFormat, "id may contain only letters, digits, '-' and '_' (up to 64 characters)")
+	case reservedIDs[b.ID]:
+		errs.add("id", CodeFormat, "id %q is reserved", b.ID)
+	}
+
+	if utf8.RuneCountInString(b.Name) > MaxNameLength {
+		errs.add("name", CodeTooLong, "name must be at most %d characters", MaxNameLength)
+	}
+
+	switch {
+	case b.Token == "":
+		errs.add("token", CodeRequired, "token is required")
+	case !tokenRe.MatchString(b.Token):
+		errs.add("token", CodeFormat, "token must look like 123456789:AA… as issued by @BotFather")
+	}
+
+	switch {
+	case b.ChannelID == 0:
+		errs.add("channel_id", CodeRequired, "channel_id is required")
+	case b.ChannelID < minChannelID || b.ChannelID > maxChannelID:
+		errs.add("channel_id", CodeRange, "channel_id must be a channel ID of the form -100…")
+	}
+
+	if b.InviteLink != "" {
+		if u, err := url.Parse(b.InviteLink); err != nil || (u.Scheme != "https" && u.Scheme != "tg") || (u.Scheme == "https" && u.Host == "") {
+			errs.add("invite_link", CodeInvalidURL, "invite_link must be an https:// or tg:// link")
+		}
+	}
+
+	// With a welcome image the welcome message is sent as its caption.
+	welcomeLimit := MaxMessageLength
+	if b.WelcomeImgKey != "" {
+		welcomeLimit = MaxCaptionLength
+	}
+	checkText(&errs, "welcome_msg", b.WelcomeMsg, welcomeLimit)
+	checkText(&errs, "not_sub_msg", b.NotSubMsg, MaxMessageLength)
+	checkText(&errs, "success_msg", b.SuccessMsg, MaxMessageLength)
+
+	if utf8.RuneCountInString(b.ButtonText) > MaxButtonLength {
+		errs.add("button_text", CodeTooLong, "button_text must be at most %d characters", MaxButtonLength)
+	}
+
+	if b.APIEndpoint != "" && !ValidEndpoint(b.APIEndpoint) {
+		errs.add("api_endpoint", CodeInvalidURL, "api_endpoint must be the http:// or https:// base URL of a Bot API server")
+	}
+
+	switch {
+	case b.Proxy == "" || b.Proxy == db.ProxyDirect:
+	case !ValidProxy(b.Proxy):
+		errs.add("proxy", CodeInvalidURL, "proxy must be %q or an http://, https:// or socks5:// URL with host and port", db.ProxyDirect)
+	case proxyMasked(b.Proxy):
+		errs.add("proxy", CodeRequired, "proxy password is masked; enter it again")
+	}
+
+	if b.StaleUpdateMinutes < 0 {
+		errs.add("stale_update_minutes", CodeRange, "stale_update_minutes must not be negative")
+	}
+
+	for _, t := range b.Tags {
+		if len(t) > MaxTagLength || !tagRe.MatchString(t) {
+			errs.add("tags", CodeFormat, "tag %q must be lower-case letters, digits, '-', '_' or '.' (up to %d characters)", t, MaxTagLength)
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// ValidEndpoint reports whether s is usable as a Bot API server base URL:
+// http or https with a host and no query or fragment.
+func ValidEndpoint(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" &&
+		u.RawQuery == "" && u.Fragment == ""
+}
+
+// ValidProxy reports whether s is a usable proxy URL: http, https or socks5,
+// with host and port and optional credentials.
+func ValidProxy(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil || u.Hostname() == "" || u.Port() == "" || (u.Path != "" && u.Path != "/") {
+		return false
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+		return true
+	}
+	return false
+}
+
+// proxyMasked reports whether the proxy URL carries the masked password
+// handed out by the API instead of the real one.
+func proxyMasked(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return false
+	}
+	pw, _ := u.User.Password()
+	return pw == db.ProxyMask
+}
+
+func checkText(errs *Errors, field, text string, limit int) {
+	text = strings.ReplaceAll(text, `\n`, "\n")
+	if n := utf8.RuneCountInString(text); n > limit {
+		errs.add(field, CodeTooLong, "%s is %d characters, Telegram allows %d", field, n, limit)
+	}
+	if err := botrunner.CheckMarkdown(text); err != nil {
+		errs.add(field, CodeMarkdown, "cannot be sent as MarkdownV2: %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package validation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"bot-manager/internal/db"
+)
+
+func validBot() db.Bot {
+	return db.Bot{
+		ID:         "gate",
+		Name:       "Gate",
+		Type:       db.BotTypeDocument,
+		Token:      "123456789:AAbbccddeeffgghhiijjkkllmmnnooppqqr",
+		ChannelID:  -1001234567890,
+		WelcomeMsg: "Hello, *friend*",
+	}
+}
+
+// fields returns the fields err reports, in order.
+func fields(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var errs Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("error %v is not Errors", err)
+	}
+	out := make([]string, len(errs))
+	for i, fe := range errs {
+		out[i] = fe.Field
+	}
+	return out
+}
+
+func TestValidID(t *testing.T) {
+	for _, tc := range []struct {
+		id string
+		ok bool
+	}{
+		{"gate", true},
+		{"Gate_2-b", true},
+		{"0", true},
+		{strings.Repeat("a", 64), true},
+		{"", false},
+		{strings.Repeat("a", 65), false},
+		{"-gate", false},
+		{"_gate", false},
+		{"a/b", false},
+		{"..", false},
+		{"a.b", false},
+		{"a b", false},
+		{"ünï", false},
+		{"templates", false},
+		{"Templates", true}, // object keys are case-sensitive
+	} {
+		if got := ValidID(tc.id); got != tc.ok {
+			t.Errorf("ValidID(%q) = %v, want %v", tc.id, got, tc.ok)
+		}
+	}
+}
+
+func TestBot(t *testing.T) {
+	if err := Bot(validBot()); err != nil {
+		t.Fatalf("valid bot: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		change func(*db.Bot)
+		want   string // the one field reported; "" for none
+	}{
+		{"missing id", func(b *db.Bot) { b.ID = "" }, "id"},
+		{"id with slash", func(b *db.Bot) { b.ID = "a/b" }, "id"},
+		{"reserved id", func(b *db.Bot) { b.ID = "templates" }, "id"},
+		{"long name", func(b *db.Bot) { b.Name = strings.Repeat("я", MaxNameLength+1) }, "name"},
+		{"name at the limit", func(b *db.Bot) { b.Name = strings.Repeat("я", MaxNameLength) }, ""},
+		{"missing token", func(b *db.Bot) { b.Token = "" }, "token"},
+		{"malformed token", func(b *db.Bot) { b.Token = "123:short" }, "token"},
+		{"missing channel", func(b *db.Bot) { b.ChannelID = 0 }, "channel_id"},
+		{"group instead of channel", func(b *db.Bot) { b.ChannelID = -123456 }, "channel_id"},
+		{"positive channel", func(b *db.Bot) { b.ChannelID = 1001234567890 }, "channel_id"},
+		{"https invite", func(b *db.Bot) { b.InviteLink = "https://t.me/+abc" }, ""},
+		{"tg invite", func(b *db.Bot) { b.InviteLink = "tg://join?invite=abc" }, ""},
+		{"http invite", func(b *db.Bot) { b.InviteLink = "http://t.me/+abc" }, "invite_link"},
+		{"invite without host", func(b *db.Bot) { b.InviteLink = "https:///x" }, "invite_link"},
+		{"long welcome", func(b *db.Bot) { b.WelcomeMsg = strings.Repeat("a", MaxMessageLength+1) }, "welcome_msg"},
+		{"welcome at the limit", func(b *db.Bot) { b.WelcomeMsg = strings.Repeat("a", MaxMessageLength) }, ""},
+		{"caption over the limit", func(b *db.Bot) {
+			b.WelcomeImgKey = "gate/welcome/img.png"
+			b.WelcomeMsg = strings.Repeat("a", MaxCaptionLength+1)
+		}, "welcome_msg"},
+		{"escaped newlines count once", func(b *db.Bot) { b.SuccessMsg = strings.Repeat(`\n`, MaxMessageLength) }, ""},
+		{"backslash in code", func(b *db.Bot) { b.NotSubMsg = "run `a\\b`" }, "not_sub_msg"},
+		{"link without url", func(b *db.Bot) { b.SuccessMsg = "[here]( )" }, "success_msg"},
+		{"long button", func(b *db.Bot) { b.ButtonText = strings.Repeat("b", MaxButtonLength+1) }, "button_text"},
+		{"endpoint", func(b *db.Bot) { b.APIEndpoint = "http://localhost:8081" }, ""},
+		{"endpoint with query", func(b *db.Bot) { b.APIEndpoint = "https://api.example.com/?x=1" }, "api_endpoint"},
+		{"endpoint scheme", func(b *db.Bot) { b.APIEndpoint = "ftp://api.example.com" }, "api_endpoint"},
+		{"direct proxy", func(b *db.Bot) { b.Proxy = db.ProxyDirect }, ""},
+		{"socks5 proxy", func(b *db.Bot) { b.Proxy = "socks5://user:pw@proxy:1080" }, ""},
+		{"proxy without port", func(b *db.Bot) { b.Proxy = "http://proxy" }, "proxy"},
+		{"proxy scheme", func(b *db.Bot) { b.Proxy = "socks4://proxy:1080" }, "proxy"},
+		{"proxy with path", func(b *db.Bot) { b.Proxy = "http://proxy:8080/x" }, "proxy"},
+		{"masked proxy password", func(b *db.Bot) { b.Proxy = "socks5://user:" + db.ProxyMask + "@proxy:1080" }, "proxy"},
+		{"negative stale minutes", func(b *db.Bot) { b.StaleUpdateMinutes = -1 }, "stale_update_minutes"},
+		{"tags", func(b *db.Bot) { b.Tags = []string{"prod", "eu-1", "v1.2"} }, ""},
+		{"upper-case tag", func(b *db.Bot) { b.Tags = []string{"Prod"} }, "tags"},
+		{"long tag", func(b *db.Bot) { b.Tags = []string{strings.Repeat("t", MaxTagLength+1)} }, "tags"},
+	} {
+		b := validBot()
+		tc.change(&b)
+		got := fields(t, Bot(b))
+		switch {
+		case tc.want == "" && len(got) != 0:
+			t.Errorf("%s: unexpected errors %v", tc.name, got)
+		case tc.want != "" && (len(got) != 1 || got[0] != tc.want):
+			t.Errorf("%s: errors %v, want [%s]", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestBotReportsEveryField(t *testing.T) {
+	got := fields(t, Bot(db.Bot{}))
+	want := []string{"id", "token", "channel_id"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("empty bot: %v, want %v", got, want)
+	}
+}
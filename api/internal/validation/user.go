@@ -0,0 +1,121 @@
+package validation
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"bot-manager/internal/db"
+)
+
+// Password policy: at least MinPasswordLength characters with at least three
+// kinds of characters (lower case, upper case, digits, others), or a
+// passphrase of at least MinPassphraseLength characters of any kind. Either
+// way it must not contain the username or be a well-known password.
+const (
+	MinPasswordLength   = 10
+	MinPassphraseLength = 16
+)
+
+// CodeWeak is the error code of a password that fails the policy.
+const CodeWeak = "weak_password"
+
+var usernameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._@-]{0,63}$`)
+
+// commonPasswords are rejected even though they pass the length and
+// character rules once capitalised ("Password123"); compared in lower case.
+var commonPasswords = map[string]bool{
+	"password123": true, "password1234": true, "p@ssw0rd123": true, "passw0rd123": true,
+	"qwerty1234": true, "qwerty123456": true, "qwertyuiop": true, "1q2w3e4r5t": true,
+	"admin12345": true, "admin123456": true, "administrator": true, "changeme123": true,
+	"welcome123": true, "letmein123": true, "iloveyou123": true, "botmanager": true,
+	"botmanager123": true, "telegram123": true,
+}
+
+// User validates an account before it is saved, with password as the new
+// password in plaintext. An empty password is accepted only for a user that
+// already has one (u.PasswordHash set), meaning it stays as it is. It
+// returns nil or an Errors value.
+func User(u db.User, password string) error {
+	var errs Errors
+
+	switch {
+	case u.Username == "":
+		errs.add("username", CodeRequired, "username is required")
+	case !usernameRe.MatchString(u.Username):
+		errs.add("username", CodeFormat, "username may contain only letters, digits, '.', '_', '-' and '@' (up to 64 characters)")
+	}
+
+	switch {
+	case u.Role == "":
+		errs.add("role", CodeRequired, "role is required")
+	case !u.Role.Valid():
+		errs.add("role", CodeFormat, "role must be one of owner, editor, operator, viewer")
+	}
+
+	switch {
+	case password == "" && u.PasswordHash == "":
+		errs.add("password", CodeRequired, "password is required")
+	case password != "":
+		checkPassword(&errs, "password", password, u.Username)
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// Password checks a new password of username against the policy, reporting
+// problems under field. It returns nil or an Errors value.
+func Password(field, password, username string) error {
+	var errs Errors
+	if password == "" {
+		errs.add(field, CodeRequired, "%s is required", field)
+	} else {
+		checkPassword(&errs, field, password, username)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func checkPassword(errs *Errors, field, password, username string) {
+	n := utf8.RuneCountInString(password)
+	lower := strings.ToLower(password)
+	switch {
+	case n < MinPasswordLength:
+		errs.add(field, CodeWeak, "password must be at least %d characters", MinPasswordLength)
+	case n < MinPassphraseLength && characterKinds(password) < 3:
+		errs.add(field, CodeWeak, "password must mix at least three of lower case, upper case, digits and symbols, or be at least %d characters", MinPassphraseLength)
+	case commonPasswords[lower]:
+		errs.add(field, CodeWeak, "password is too common")
+	case username != "" && strings.Contains(lower, strings.ToLower(username)):
+		errs.add(field, CodeWeak, "password must not contain the username")
+	}
+}
+
+func characterKinds(s string) int {
+	var lower, upper, digit, other bool
+	for _, r := range s {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+	kinds := 0
+	for _, ok := range []bool{lower, upper, digit, other} {
+		if ok {
+			kinds++
+		}
+	}
+	return kinds
+}
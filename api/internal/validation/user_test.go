@@ -0,0 +1,85 @@
+package validation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"bot-manager/internal/db"
+)
+
+func TestPassword(t *testing.T) {
+	for _, tc := range []struct {
+		password string
+		ok       bool
+	}{
+		{"", false},
+		{"Short1!", false},
+		{"Abcdefgh1", false},            // 9 characters
+		{"Abcdefgh12", true},            // 10, three kinds
+		{"abcdefgh1!", true},            // lower, digit, symbol
+		{"abcdefghij", false},           // one kind
+		{"Abcdefghij", false},           // two kinds
+		{"abcdefghijklmnop", true},      // passphrase length, any kind
+		{"abcdefghijklmno", false},      // one short of a passphrase
+		{"correct horse battery", true}, // spaces count as symbols and length
+		{"Password123", false},          // common
+		{"QWERTY123456", false},         // common, any case
+		{"Xavier-2024!", false},         // contains the username
+		{"XAVIER passphrase", false},    // any case
+		{"ÄäÖöÜü12345", true},           // non-ASCII letters have case
+	} {
+		err := Password("new_password", tc.password, "xavier")
+		if (err == nil) != tc.ok {
+			t.Errorf("Password(%q) = %v, want ok=%v", tc.password, err, tc.ok)
+			continue
+		}
+		if err == nil {
+			continue
+		}
+		var errs Errors
+		if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Field != "new_password" {
+			t.Errorf("Password(%q) = %#v", tc.password, err)
+			continue
+		}
+		want := CodeWeak
+		if tc.password == "" {
+			want = CodeRequired
+		}
+		if errs[0].Code != want {
+			t.Errorf("Password(%q) code = %q, want %q", tc.password, errs[0].Code, want)
+		}
+	}
+
+	// Without a username only the other rules apply.
+	if err := Password("password", "Xavier-2024!", ""); err != nil {
+		t.Errorf("without username: %v", err)
+	}
+}
+
+func TestUser(t *testing.T) {
+	const good = "Long enough 1"
+	for _, tc := range []struct {
+		name     string
+		user     db.User
+		password string
+		want     []string
+	}{
+		{"valid", db.User{Username: "alice", Role: db.RoleEditor}, good, nil},
+		{"email username", db.User{Username: "alice.b@example.com", Role: db.RoleViewer}, good, nil},
+		{"empty", db.User{}, "", []string{"username", "role", "password"}},
+		{"bad username", db.User{Username: "alice smith", Role: db.RoleViewer}, good, []string{"username"}},
+		{"leading dot", db.User{Username: ".alice", Role: db.RoleViewer}, good, []string{"username"}},
+		{"long username", db.User{Username: strings.Repeat("a", 65), Role: db.RoleViewer}, good, []string{"username"}},
+		{"unknown role", db.User{Username: "alice", Role: "admin"}, good, []string{"role"}},
+		{"weak password", db.User{Username: "alice", Role: db.RoleOwner}, "short", []string{"password"}},
+		{"password with username", db.User{Username: "alice", Role: db.RoleOwner}, "Alice's password 1", []string{"password"}},
+		{"keep existing password", db.User{Username: "alice", Role: db.RoleOwner, PasswordHash: "hash"}, "", nil},
+		{"new password checked", db.User{Username: "alice", Role: db.RoleOwner, PasswordHash: "hash"}, "short", []string{"password"}},
+	} {
+		got := fields(t, User(tc.user, tc.password))
+		if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+			t.Errorf("%s: errors %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -1,10 +1,10 @@
 const BASE = ''
 
-async function request<T>(method: string, path: string, body?: unknown): Promise<T> {
+async function request<T>(method: string, path: string, body?: unknown, headers: Record<string, string> = {}): Promise<T> {
   const res = await fetch(BASE + path, {
     method,
     credentials: 'include',
-    headers: body ? { 'Content-Type': 'application/json' } : {},
+    headers: body ? { 'Content-Type': 'application/json', ...headers } : headers,
     body: body ? JSON.stringify(body) : undefined,
   })
 
@@ -39,7 +39,26 @@ export const api = {
     login: (username: string, password: string) =>
       request<{ ok: boolean }>('POST', '/api/auth/login', { username, password }),
     logout: () => request<{ ok: boolean }>('POST', '/api/auth/logout'),
-    me: () => request<{ username: string }>('GET', '/api/auth/me'),
+    me: () => request<{ username: string; role: import('@/types').Role }>('GET', '/api/auth/me'),
+    changePassword: (currentPassword: string, newPassword: string) =>
+      request<{ ok: boolean }>('POST', '/api/auth/password', {
+        current_password: currentPassword,
+        new_password: newPassword,
+      }),
+    sessions: () => request<import('@/types').Session[]>('GET', '/api/auth/sessions'),
+    revokeSession: (id: string) =>
+      request<void>('DELETE', `/api/auth/sessions/${encodeURIComponent(id)}`),
+    revokeOtherSessions: () => request<{ revoked: number }>('DELETE', '/api/auth/sessions'),
+  },
+
+  // Owners only.
+  users: {
+    list: () => request<import('@/types').User[]>('GET', '/api/users'),
+    create: (params: { username: string; password: string; role: import('@/types').Role }) =>
+      request<import('@/types').User>('POST', '/api/users', params),
+    update: (username: string, changes: { password?: string; role?: import('@/types').Role }) =>
+      request<import('@/types').User>('PATCH', `/api/users/${encodeURIComponent(username)}`, changes),
+    delete: (username: string) => request<void>('DELETE', `/api/users/${encodeURIComponent(username)}`),
   },
 
   bots: {
@@ -49,11 +68,28 @@ export const api = {
       request<import('@/types').Bot>('POST', '/api/bots', bot),
     update: (id: string, bot: Partial<import('@/types').Bot>) =>
       request<import('@/types').Bot>('PUT', `/api/bots/${id}`, bot),
+    // JSON merge patch; pass the ETag from a previous response to fail with 412 on concurrent edits.
+    patch: (id: string, changes: Partial<import('@/types').Bot>, etag?: string) =>
+      request<import('@/types').Bot>('PATCH', `/api/bots/${id}`, changes, etag ? { 'If-Match': etag } : {}),
     delete: (id: string) => request<void>('DELETE', `/api/bots/${id}`),
     start: (id: string) => request<{ ok: boolean }>('POST', `/api/bots/${id}/start`),
     stop: (id: string) => request<{ ok: boolean }>('POST', `/api/bots/${id}/stop`),
     restart: (id: string) => request<{ ok: boolean }>('POST', `/api/bots/${id}/restart`),
     logs: (id: string) => request<string[]>('GET', `/api/bots/${id}/logs`),
+    revisions: (id: string) => request<import('@/types').Revision[]>('GET', `/api/bots/${id}/revisions`),
+    diffRevisions: (id: string, from: number, to?: number) =>
+      request<{ from: number; to: number; changes: import('@/types').FieldChange[] }>(
+        'GET', `/api/bots/${id}/revisions/diff?from=${from}${to ? `&to=${to}` : ''}`),
+    restoreRevision: (id: string, rev: number) =>
+      request<import('@/types').Bot>('POST', `/api/bots/${id}/revisions/${rev}/restore`),
+    validate: (bot: Partial<import('@/types').Bot>) =>
+      request<import('@/types').VerifyResult>('POST', '/api/bots/validate', bot),
+    resolveChat: (params: { id?: string; token?: string; chat?: string; forwarded_message_id?: number }) =>
+      request<import('@/types').ResolvedChat>('POST', '/api/bots/resolve-chat', params),
+    chats: (id: string) => request<import('@/types').BotChat[]>('GET', `/api/bots/${id}/chats`),
+    revealToken: (id: string) => request<{ token: string }>('POST', `/api/bots/${id}/token/reveal`),
+    clone: (id: string, params: { id: string; name?: string; token: string; channel_id: number }) =>
+      request<import('@/types').Bot>('POST', `/api/bots/${id}/clone`, params),
   },
 
   assets: {
@@ -72,9 +108,24 @@ export const api = {
       request<void>('DELETE', `/api/bots/${botId}/assets/${encodeURIComponent(key)}`),
   },
 
+  templates: {
+    list: () => request<import('@/types').Template[]>('GET', '/api/templates'),
+    get: (id: string) => request<import('@/types').Template>('GET', `/api/templates/${id}`),
+    save: (botId: string, params: { id: string; name: string; description?: string }) =>
+      request<import('@/types').Template>('POST', `/api/bots/${botId}/template`, params),
+    createBot: (id: string, params: { id: string; name?: string; token: string; channel_id: number }) =>
+      request<import('@/types').Bot>('POST', `/api/templates/${id}/bots`, params),
+    delete: (id: string) => request<void>('DELETE', `/api/templates/${id}`),
+  },
+
   // Export / Import
-  exportURL: (format: 'json' | 'zip' = 'json') =>
-    `/api/export${format === 'zip' ? '?format=zip' : ''}`,
+  exportURL: (format: 'json' | 'zip' = 'json', includeTokens = true) => {
+    const params = new URLSearchParams()
+    if (format === 'zip') params.set('format', 'zip')
+    if (!includeTokens) params.set('include_tokens', 'false')
+    const qs = params.toString()
+    return `/api/export${qs ? `?${qs}` : ''}`
+  },
 
   importJSON: (data: unknown) =>
     request<import('@/types').ImportResult>('POST', '/api/import', data),
@@ -18,8 +18,10 @@ import {
 function statusVariant(s: BotStatus) {
   switch (s) {
     case 'running': return 'success'
-    case 'starting': return 'warning'
-    case 'error': return 'destructive'
+    case 'starting':
+    case 'degraded': return 'warning'
+    case 'error':
+    case 'conflict': return 'destructive'
     default: return 'secondary'
   }
 }
@@ -29,6 +31,8 @@ function statusLabel(s: BotStatus) {
     case 'running': return 'Работает'
     case 'starting': return 'Запускается'
     case 'error': return 'Ошибка'
+    case 'conflict': return 'Конфликт токена'
+    case 'degraded': return 'Завис'
     default: return 'Остановлен'
   }
 }
@@ -36,8 +40,10 @@ function statusLabel(s: BotStatus) {
 function statusAccent(s: BotStatus) {
   switch (s) {
     case 'running':  return 'border-l-green-500'
-    case 'starting': return 'border-l-yellow-400'
-    case 'error':    return 'border-l-destructive'
+    case 'starting':
+    case 'degraded': return 'border-l-yellow-400'
+    case 'error':
+    case 'conflict': return 'border-l-destructive'
     default:         return 'border-l-border'
   }
 }
@@ -50,7 +56,7 @@ function BotCard({ bot }: { bot: BotSnapshot }) {
   const stop    = useMutation({ mutationFn: () => api.bots.stop(bot.id),    onSuccess: refetch })
   const restart = useMutation({ mutationFn: () => api.bots.restart(bot.id), onSuccess: refetch })
 
-  const isRunning = bot.status === 'running' || bot.status === 'starting'
+  const isRunning = bot.status === 'running' || bot.status === 'starting' || bot.status === 'degraded'
   const isLoading = start.isPending || stop.isPending || restart.isPending
 
   return (
@@ -59,7 +65,9 @@ function BotCard({ bot }: { bot: BotSnapshot }) {
         <div className="flex items-start justify-between gap-2">
           <div className="flex-1 min-w-0">
             <CardTitle className="text-base truncate leading-tight">{bot.name}</CardTitle>
-            <p className="text-xs text-muted-foreground mt-0.5 font-mono truncate">{bot.id}</p>
+            <p className="text-xs text-muted-foreground mt-0.5 font-mono truncate">
+              {bot.id}{bot.username && ` · @${bot.username}`}
+            </p>
           </div>
           <Badge variant={statusVariant(bot.status)} className="shrink-0 gap-1">
             {bot.status === 'running' && (
@@ -70,7 +78,7 @@ function BotCard({ bot }: { bot: BotSnapshot }) {
         </div>
       </CardHeader>
       <CardContent className="flex-1 space-y-3 pt-0">
-        {bot.status === 'error' && (
+        {(bot.status === 'error' || bot.status === 'conflict' || bot.status === 'degraded') && (
           <p className="text-xs text-destructive bg-destructive/10 rounded-md px-2 py-1.5 break-all">
             {bot.status_msg || 'Неизвестная ошибка'}
           </p>
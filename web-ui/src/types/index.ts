@@ -2,13 +2,13 @@
 // All bots now use unified logic: files if assets exist, text otherwise.
 export type BotType = string
 
-export type BotStatus = 'stopped' | 'starting' | 'running' | 'error'
+export type BotStatus = 'stopped' | 'starting' | 'running' | 'error' | 'conflict' | 'degraded'
 
 export interface Bot {
   id: string
   name: string
   type: BotType
-  token: string
+  token: string // masked in responses; send it back unchanged (or empty) to keep it
   channel_id: number
   invite_link: string
   welcome_img_key: string
@@ -18,6 +18,10 @@ export interface Bot {
   not_sub_msg: string
   success_msg: string
   enabled: boolean
+  stale_update_minutes: number // skip updates older than N minutes after downtime; 0 = never
+  tags: string[]
+  api_endpoint: string // Bot API server base URL; empty = server default
+  proxy: string // http/https/socks5 URL (password masked); empty = server default, 'direct' = none
   created_at: string
   updated_at: string
 }
@@ -29,6 +33,66 @@ export interface BotSnapshot {
   status: BotStatus
   status_msg: string
   enabled: boolean
+  tags: string[]
+  owner?: string // replica running the bot
+  // Runtime details; absent for bots running on another replica.
+  username?: string
+  started_at?: string
+  uptime_seconds?: number
+  last_update_at?: string
+  restart_count?: number
+  counters?: {
+    updates: number
+    callbacks: number
+    deliveries: number
+    errors: number
+  }
+}
+
+export type BulkAction = 'start' | 'stop' | 'restart' | 'enable' | 'disable' | 'delete'
+
+export interface BulkResult {
+  id: string
+  ok: boolean
+  error?: string
+}
+
+// Returned with HTTP 422 when a bot config fails validation.
+export interface FieldError {
+  field: string
+  code: string
+  message: string
+}
+
+export interface VerifyResult {
+  ok: boolean
+  bot_id?: number
+  bot_username?: string
+  chat_title?: string
+  chat_type?: string
+  chat_username?: string
+  bot_status?: string
+  missing_rights: string[]
+  errors: string[]
+}
+
+export interface ResolvedChat {
+  id: number
+  title: string
+  type: string
+  username?: string
+}
+
+// A chat the bot was added to (status) or had a post forwarded from.
+export interface BotChat {
+  bot_id: string
+  chat_id: number
+  type: string
+  title: string
+  username: string
+  status: string
+  forward_message_id?: number
+  updated_at: string
 }
 
 export interface Asset {
@@ -42,7 +106,67 @@ export interface Asset {
   url: string
 }
 
+export interface Revision {
+  bot_id: string
+  rev: number
+  author: string
+  snapshot: Bot
+  created_at: string
+}
+
+export interface FieldChange {
+  field: string
+  from: unknown
+  to: unknown
+}
+
+export interface TemplateAsset {
+  minio_key: string
+  filename: string
+  content_type: string
+  size: number
+}
+
+export interface Template {
+  id: string
+  name: string
+  description: string
+  type: BotType
+  welcome_img_key: string
+  welcome_msg: string
+  button_text: string
+  not_sub_msg: string
+  success_msg: string
+  stale_update_minutes: number
+  tags: string[]
+  assets: TemplateAsset[]
+  created_at: string
+  updated_at: string
+}
+
 export interface ImportResult {
   imported: string[]
+  templates: string[]
   errors: string[]
 }
+
+// Each role includes the rights of the ones before it.
+export type Role = 'viewer' | 'operator' | 'editor' | 'owner'
+
+export interface User {
+  username: string
+  role: Role
+  created_at: string
+  updated_at: string
+}
+
+export interface Session {
+  id: string
+  username: string
+  ip: string
+  user_agent: string
+  created_at: string
+  last_seen_at: string
+  expires_at: string
+  current: boolean
+}